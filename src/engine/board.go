@@ -25,24 +25,59 @@ type Board struct {
 
 	stateInfoArr []*StateInfo
 
-	PieceInfoArr              [64]*PieceInfo
-	RepetitionPositionHistory map[uint64]int
+	PieceInfoArr [64]*PieceInfo
 }
 
-func (board *Board) DeepCopy() (retval Board) {
-	retval = *board
+// DeepCopy returns a fully independent copy of the board, including its state history,
+// so that it can be searched and mutated on another goroutine
+func (board *Board) DeepCopy() *Board {
+	retval := &Board{
+		W:            board.W,
+		B:            board.B,
+		stateInfoArr: make([]*StateInfo, len(board.stateInfoArr), len(board.stateInfoArr)+MAX_POSSIBLE_DEPTH+MAX_QSEARCH_DEPTH),
+	}
+	// PieceInfo and StateInfo hold pointers into this board's bitboards, re-point them at the copy's
+	for i, pieceInfo := range board.PieceInfoArr {
+		retval.PieceInfoArr[i] = retval.copyPieceInfo(board, pieceInfo)
+	}
 	for i, stateInfo := range board.stateInfoArr {
 		temp := *stateInfo
+		temp.Capture = retval.copyPieceInfo(board, stateInfo.Capture)
+		temp.PrePromotionBitBoard = retval.translateBitBoard(board, stateInfo.PrePromotionBitBoard)
 		retval.stateInfoArr[i] = &temp
 	}
-	for i, pieceInfo := range board.PieceInfoArr {
-		if pieceInfo != nil {
-			temp := *pieceInfo
-			retval.PieceInfoArr[i] = &temp
+
+	return retval
+}
+
+func (board *Board) copyPieceInfo(source *Board, pieceInfo *PieceInfo) *PieceInfo {
+	if pieceInfo == nil {
+		return nil
+	}
+	temp := *pieceInfo
+	temp.thisBitBoard = board.translateBitBoard(source, pieceInfo.thisBitBoard)
+	return &temp
+}
+
+// Returns the bitboard of this board that sits in the same place as bitboard does on source
+func (board *Board) translateBitBoard(source *Board, bitboard *BitBoard) *BitBoard {
+	if bitboard == nil {
+		return nil
+	}
+	sourceBitBoards := source.pieceBitBoards()
+	for i, thisBitBoard := range board.pieceBitBoards() {
+		if sourceBitBoards[i] == bitboard {
+			return thisBitBoard
 		}
 	}
+	panic("Bitboard does not belong to the source board!")
+}
 
-	return retval
+func (board *Board) pieceBitBoards() [12]*BitBoard {
+	return [12]*BitBoard{
+		&board.W.Pawn, &board.W.Knight, &board.W.Bishop, &board.W.Rook, &board.W.Queen, &board.W.King,
+		&board.B.Pawn, &board.B.Knight, &board.B.Bishop, &board.B.Rook, &board.B.Queen, &board.B.King,
+	}
 }
 
 func (board *Board) Equal(other *Board) bool {
@@ -85,6 +120,70 @@ func (board *Board) GetTopState() *StateInfo {
 	return board.stateInfoArr[len(board.stateInfoArr)-1]
 }
 
+/*
+Returns the type of the piece that played the move plies moves ago (1 = the last move) and its target square,
+ok is false for a null move or when the game does not go back that far.
+Only the last two moves are supported, the piece of the move before the last one is found even if the last move captured it
+*/
+func (board *Board) playedMove(plies int) (pieceType int, to Position, ok bool) {
+	top := len(board.stateInfoArr) - 1
+	if plies < 1 || plies > 2 || top-plies+1 < 1 {
+		return NULL_PIECE, INVALID_POSITION, false
+	}
+	move := board.stateInfoArr[top-plies+1].PrecedentMove
+	if move == NULL_MOVE {
+		return NULL_PIECE, INVALID_POSITION, false
+	}
+	to = getTargetPosition(move)
+
+	if plies == 2 {
+		lastMove := board.stateInfoArr[top].PrecedentMove
+		if captured := board.stateInfoArr[top].Capture; captured != nil &&
+			(getTargetPosition(lastMove) == to || GetFlag(lastMove) == epCaptureFlag) {
+			return captured.pieceTYPE, to, true
+		}
+	}
+	return board.PieceInfoArr[to].pieceTYPE, to, true
+}
+
+/*
+Returns how many times the current position occurred before and how many plies ago it last did, https://www.chessprogramming.org/Repetitions
+only the positions since the last capture or pawn move are compared, as the HalfMoveClock counts them, and none before a null move
+*/
+func (board *Board) repetitions() (count int, distance int) {
+	return board.repetitionsAt(len(board.stateInfoArr) - 1)
+}
+
+// Same as repetitions, for the position of the game that was current at index top of the state stack
+func (board *Board) repetitionsAt(top int) (count int, distance int) {
+	key := board.stateInfoArr[top].ZobristKey
+	end := max(top-int(board.stateInfoArr[top].HalfMoveClock), 0)
+
+	// Only positions with the same side to move can repeat, so every other one is skipped
+	for i := top - 2; i >= end; i -= 2 {
+		if board.stateInfoArr[i+2].PrecedentMove == NULL_MOVE || board.stateInfoArr[i+1].PrecedentMove == NULL_MOVE {
+			break
+		}
+		if board.stateInfoArr[i].ZobristKey == key {
+			if count == 0 {
+				distance = top - i
+			}
+			count++
+		}
+	}
+	return count, distance
+}
+
+/*
+Whether the current position is drawn by repetition for a search plyFromRoot plies deep.
+A single repetition of a position reached inside the search is enough, if it was good the side to move could have made progress instead,
+a position that only repeats the game up to and including the root still needs to have occurred twice, as for the threefold repetition
+*/
+func (board *Board) isRepetition(plyFromRoot int8) bool {
+	count, distance := board.repetitions()
+	return count >= 2 || (count == 1 && distance < int(plyFromRoot))
+}
+
 func (board *Board) pushNewState(newState *StateInfo) {
 	board.stateInfoArr = append(board.stateInfoArr, newState)
 }
@@ -99,6 +198,24 @@ func (board *Board) PopTopState() *StateInfo {
 	return retval
 }
 
+// Returns the occupancy of the side to move and of the side waiting, derived from the piece bitboards
+// so that move generation only ever reads from the board
+func (board *Board) sideOccupancy() (friendBitBoard, enemyBitBoard BitBoard) {
+	if board.GetTopState().TurnColor == WHITE {
+		return board.W.OccupancyBitBoard(), board.B.OccupancyBitBoard()
+	}
+	return board.B.OccupancyBitBoard(), board.W.OccupancyBitBoard()
+}
+
+// Whether the side to move has a piece other than pawns and its king, without one null move pruning is unsafe because of zugzwang
+func (board *Board) hasNonPawnMaterial() bool {
+	pieces := &board.W
+	if board.GetTopState().TurnColor == BLACK {
+		pieces = &board.B
+	}
+	return pieces.Knight|pieces.Bishop|pieces.Rook|pieces.Queen != 0
+}
+
 func (board *Board) InCheck() bool {
 	return board.stateInfoArr[len(board.stateInfoArr)-1].inCheck
 }
@@ -119,8 +236,7 @@ func InitFENBoard(FEN string) *Board {
 	turnCount := FEN_Arr[5]
 
 	retval := &Board{
-		stateInfoArr:              []*StateInfo{{}},
-		RepetitionPositionHistory: make(map[uint64]int),
+		stateInfoArr: []*StateInfo{{}},
 	}
 
 	var position Position = A8
@@ -162,14 +278,10 @@ func InitFENBoard(FEN string) *Board {
 		retval.GetTopState().TurnColor = WHITE
 		kingPosition := retval.W.King
 		retval.GetTopState().inCheck = retval.isAttacked(PopLSB(&kingPosition), BLACK)
-		friendBitboard = retval.W.OccupancyBitBoard()
-		enemyBitBoard = retval.B.OccupancyBitBoard()
 	} else if fenColor == "b" {
 		retval.GetTopState().TurnColor = BLACK
 		kingPosition := retval.B.King
 		retval.GetTopState().inCheck = retval.isAttacked(PopLSB(&kingPosition), WHITE)
-		friendBitboard = retval.B.OccupancyBitBoard()
-		enemyBitBoard = retval.W.OccupancyBitBoard()
 	} else {
 		panic("need a proper fenColor!")
 	}
@@ -182,7 +294,6 @@ func InitFENBoard(FEN string) *Board {
 		temp, _ := strconv.Atoi(turnCount)
 		retval.GetTopState().TurnCounter = byte(temp)
 	}
-	totalBitBoard = friendBitboard | enemyBitBoard
 	retval.GetTopState().useOpeningBook = true
 
 	retval.computeZobristHash()
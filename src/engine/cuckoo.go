@@ -0,0 +1,125 @@
+package chessengine
+
+/*
+Cuckoo tables of every reversible move, to find in one lookup whether a zobrist key difference is a single move,
+https://www.chessprogramming.org/Repetitions#Cuckoo_Tables and Marcel van Kervinck's "The design and implementation of the Rookie 2.0 chess playing program".
+A move's key is what it changes in the zobrist key: the piece leaving and reaching a square and the side to move.
+Pawn moves, captures and castling can never be undone, so only the 3668 knight, bishop, rook, queen and king moves between two squares are stored
+*/
+const cuckooSize = 8192
+
+var cuckooKeys [cuckooSize]uint64
+var cuckooMoves [cuckooSize]Move
+
+func cuckooHash1(key uint64) uint64 {
+	return key & (cuckooSize - 1)
+}
+
+func cuckooHash2(key uint64) uint64 {
+	return (key >> 16) & (cuckooSize - 1)
+}
+
+// Fills the cuckoo tables from the zobrist keys, every move is stored once with its squares in either order
+func initCuckoo() {
+	cuckooKeys = [cuckooSize]uint64{}
+	cuckooMoves = [cuckooSize]Move{}
+	for pieceType := KNIGHT; pieceType <= KING; pieceType++ {
+		for color := WHITE; color <= BLACK; color++ {
+			for from := Position(0); from < 64; from++ {
+				for to := from + 1; to < 64; to++ {
+					if emptyBoardAttacks(pieceType, from)&(BitBoard(1)<<to) == 0 {
+						continue
+					}
+					key := zobristPieceArr[pieceType][color][from] ^ zobristPieceArr[pieceType][color][to] ^ zobristWhiteSideToMove
+					move := NewMove(from, to, quietFlag)
+
+					// Insert by kicking out whatever is in the way to its other slot, until an empty slot is reached
+					slot := cuckooHash1(key)
+					for {
+						cuckooKeys[slot], key = key, cuckooKeys[slot]
+						cuckooMoves[slot], move = move, cuckooMoves[slot]
+						if move == NULL_MOVE {
+							break
+						}
+						if slot == cuckooHash1(key) {
+							slot = cuckooHash2(key)
+						} else {
+							slot = cuckooHash1(key)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func emptyBoardAttacks(pieceType int, position Position) BitBoard {
+	switch pieceType {
+	case KNIGHT:
+		return knightMoveBoard[position]
+	case BISHOP:
+		return manualValidBishopMovesBitBoard(position, 0)
+	case ROOK:
+		return manualValidRookMovesBitBoard(position, 0)
+	case QUEEN:
+		return manualValidBishopMovesBitBoard(position, 0) | manualValidRookMovesBitBoard(position, 0)
+	case KING:
+		return kingMoveBoard[position]
+	}
+	return 0
+}
+
+/*
+Whether the side to move has a move that repeats a position, drawn for a search plyFromRoot plies deep as isRepetition would find it after the move.
+Only the key differences to the positions an odd number of plies ago are looked up, the side to move differs from theirs,
+so a single move of the side to move leading back to one of them is a key found in the cuckoo tables.
+The move has to be possible, nothing may stand between its squares, it is not checked to be legal
+*/
+func (board *Board) hasUpcomingRepetition(plyFromRoot int8) bool {
+	top := len(board.stateInfoArr) - 1
+	end := min(int(board.stateInfoArr[top].HalfMoveClock), top)
+	if end < 3 {
+		return false
+	}
+
+	// The positions before a null move are not reached by moves, as in repetitions
+	if board.stateInfoArr[top].PrecedentMove == NULL_MOVE {
+		return false
+	}
+	key := board.stateInfoArr[top].ZobristKey
+	occupied := board.W.OccupancyBitBoard() | board.B.OccupancyBitBoard()
+	for i := 3; i <= end; i += 2 {
+		if board.stateInfoArr[top-i+2].PrecedentMove == NULL_MOVE || board.stateInfoArr[top-i+1].PrecedentMove == NULL_MOVE {
+			return false
+		}
+		moveKey := key ^ board.stateInfoArr[top-i].ZobristKey
+		slot := cuckooHash1(moveKey)
+		if cuckooKeys[slot] != moveKey {
+			if slot = cuckooHash2(moveKey); cuckooKeys[slot] != moveKey {
+				continue
+			}
+		}
+
+		move := cuckooMoves[slot]
+		from, to := getStartingPosition(move), getTargetPosition(move)
+		if getIntermediaryRay(from, to)&occupied != 0 {
+			continue
+		}
+		// The moved piece has to belong to the side to move, one of the opponent's moves back is not for it to play
+		piece := board.PieceInfoArr[from]
+		if piece == nil {
+			piece = board.PieceInfoArr[to]
+		}
+		if piece == nil || piece.color != board.stateInfoArr[top].TurnColor {
+			continue
+		}
+		// The position repeated is reached inside the search after the root, otherwise it needs to have occurred twice already
+		if i < int(plyFromRoot) {
+			return true
+		}
+		if count, _ := board.repetitionsAt(top - i); count > 0 {
+			return true
+		}
+	}
+	return false
+}
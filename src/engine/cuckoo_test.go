@@ -0,0 +1,60 @@
+package chessengine
+
+import (
+	"testing"
+)
+
+func Test_CuckooTable(t *testing.T) {
+	InitZobristTable()
+
+	count := 0
+	for _, key := range cuckooKeys {
+		if key != 0 {
+			count++
+		}
+	}
+	if count != 3668 {
+		t.Fatalf("Cuckoo table holds %d moves, wanted 3668", count)
+	}
+}
+
+func Test_UpcomingRepetition(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+
+	// Black can move its knight back to the start position
+	test := InitStartBoard()
+	for _, move := range []string{"g1f3", "g8f6", "f3g1"} {
+		playMove(t, test, move)
+	}
+	if !test.hasUpcomingRepetition(4) {
+		t.Fatalf("Upcoming repetition of a position inside the search not found")
+	}
+	// The start position is the root or before it, a single repetition of it is no draw yet
+	if test.hasUpcomingRepetition(3) || test.hasUpcomingRepetition(2) {
+		t.Fatalf("Upcoming repetition found of a position before the root that only occurred once")
+	}
+	for _, move := range []string{"f6g8", "g1f3", "g8f6", "f3g1"} {
+		playMove(t, test, move)
+	}
+	if !test.hasUpcomingRepetition(0) {
+		t.Fatalf("Upcoming threefold repetition not found")
+	}
+
+	// The rook went around to a8, going back along the a-file is only possible without the pawn in the way
+	moves := []string{"h6g6", "a1b1", "g6g7", "b1b8", "g7h7", "b8a8", "h7h6"}
+	test = InitFENBoard("8/8/7k/8/8/8/8/R3K3 b - - 0 1")
+	for _, move := range moves {
+		playMove(t, test, move)
+	}
+	if !test.hasUpcomingRepetition(8) {
+		t.Fatalf("Upcoming repetition by the rook not found")
+	}
+	test = InitFENBoard("8/8/7k/p7/8/8/8/R3K3 b - - 0 1")
+	for _, move := range moves {
+		playMove(t, test, move)
+	}
+	if test.hasUpcomingRepetition(8) {
+		t.Fatalf("Upcoming repetition found through a blocked file")
+	}
+}
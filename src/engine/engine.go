@@ -0,0 +1,349 @@
+package chessengine
+
+import (
+	timemanager "chessengine/src/timemanager"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Engine owns everything a search needs besides the board itself:
+the transposition table, the search threads with their move pools, PV tables and history/killer heuristics,
+and the statistics of the latest search. Separate engines share nothing, so several can search at once,
+e.g. one engine per game on a server
+*/
+type Engine struct {
+	tt      *TranspositionTable
+	threads []*searchThread // threads[0] is the main thread, the rest are Lazy SMP helpers
+	multiPV int             // Number of best root moves the main thread searches and reports a line for
+	margins PruningMargins
+	iiMode  InternalIterativeMode
+
+	nodeLimit  uint64 // Node budget of the current search, 0 = no limit
+	mateSearch bool   // The current search only looks for a forced mate
+	// Decides after every iteration of the current search whether to start the next one and ends it at its hard limit, nil = no time limit
+	timeManager *timemanager.TimeManager
+	observer    Observer     // Follows the main thread of the current search, nil = nobody
+	stopped     *atomic.Bool // Set once every thread of the current search has to stop, each search has its own
+}
+
+// Margins of the static eval based pruning at shallow depth, in centipawns per ply of depth left.
+// Raising a margin prunes less and searches more safely
+type PruningMargins struct {
+	ReverseFutility int // A node whose static eval is this far above beta returns without a search
+	Futility        int // Quiet moves are skipped at nodes whose static eval is this far below alpha
+	Razoring        int // Nodes whose static eval is this far below alpha only get a quiescence search
+}
+
+var DefaultPruningMargins = PruningMargins{ReverseFutility: 90, Futility: 150, Razoring: 250}
+
+// How the search makes up for a node at high depth that has no TT move to search first, IIR by default
+type InternalIterativeMode byte
+
+const (
+	InternalIterativeOff        InternalIterativeMode = iota // The node is searched as it is, with the ordering of the move picker
+	InternalIterativeReductions                              // The node is searched one ply shallower, https://www.chessprogramming.org/Internal_Iterative_Reductions
+)
+
+// SearchLimits bounds a single call to Engine.Search
+type SearchLimits struct {
+	StartTime     time.Time     // Reference point for the reported time/nps, defaults to time.Now()
+	Depth         int8          // Maximum depth to search, 0 = MAX_SEARCH_DEPTH
+	CancelChannel chan struct{} // Closing this channel stops the search, nil = search until Depth is reached
+	SearchMoves   []Move        // Restricts the root to these moves, which all have to be legal, nil = all legal moves
+	Nodes         uint64        // Stops the search once all threads together have searched this many nodes, 0 = no limit
+	Mate          int8          // Searches the main thread only for a forced mate in at most this many moves, 0 = normal search
+
+	// Ends the search on the clock, its hard limit has to be started by the caller, nil = no time limit
+	TimeManager *timemanager.TimeManager
+
+	// Searches with the main thread only, on a cleared transposition table and cleared histories,
+	// so that the same position, hash size and limits always give the same result
+	Deterministic bool
+
+	// Gets the lines and root moves of the main thread while it searches, nil = the search runs silently
+	Observer Observer
+}
+
+// Tells whether a score is exact, or only a bound from an aspiration search that failed high or low
+type ScoreBound byte
+
+const (
+	ExactBound ScoreBound = iota
+	LowerBound            // The score is at least this, the search failed high
+	UpperBound            // The score is at most this, the search failed low
+)
+
+// A principal variation starting with one of the root moves, along with its score and the depth it was searched to
+type PVLine struct {
+	Score    int // Centipawns, or a mate score when MateIn is not 0
+	Bound    ScoreBound
+	Depth    int8
+	SelDepth int8 // Deepest ply the iteration had reached when the line was found, quiescence search included
+	PV       []Move
+}
+
+// Returns the number of moves until mate in this line, negative when the side to move gets mated, 0 when it does not end in mate
+func (line PVLine) MateIn() int {
+	return scoreIsCheckmate(line.Score)
+}
+
+// Returns the score the way UCI prints it, "cp <centipawns>" or "mate <moves>" followed by the bound if it is not exact
+func (line PVLine) ScoreString() string {
+	retval := fmt.Sprintf("cp %d", line.Score)
+	if mateIn := line.MateIn(); mateIn != 0 {
+		retval = fmt.Sprintf("mate %d", mateIn)
+	}
+	switch line.Bound {
+	case LowerBound:
+		retval += " lowerbound"
+	case UpperBound:
+		retval += " upperbound"
+	}
+	return retval
+}
+
+// SearchResult is what a call to Engine.Search found
+type SearchResult struct {
+	BestMove   Move
+	PonderMove Move // The reply expected to BestMove, the second move of the main thread's PV or else the TT move after BestMove, NULL_MOVE if neither is known
+	// The line of BestMove with its score, depth, seldepth and full PV, the zero line when the search was stopped before it finished a root move
+	PVLine
+	Nodes uint64        // Nodes searched by all threads
+	Time  time.Duration // Time since SearchLimits.StartTime
+	Lines []PVLine      // The MultiPV lines of the main thread ordered by score, Lines[0] starts with BestMove
+	Err   error         // Set when the limits could not be searched, e.g. an illegal SearchMoves entry, nothing else is set then
+}
+
+/*
+Observer follows the main thread of a search, e.g. to print UCI info lines.
+Its methods are called from the search itself and should return quickly, helpers never call them
+*/
+type Observer interface {
+	// Called with every line of a finished iteration, with the lines the move is played from when the search is stopped,
+	// and with the bound whenever an aspiration search fails high or low
+	Iteration(info IterationInfo)
+	// Called before every root move the main thread searches
+	RootMove(info RootMoveInfo)
+}
+
+type IterationInfo struct {
+	Line      PVLine
+	MultiPV   int           // Number of the line, 1 for the best one
+	Nodes     uint64        // Nodes searched by all threads so far
+	Time      time.Duration // Time since SearchLimits.StartTime
+	HashFull  int           // Permille of the transposition table in use
+	DebugInfo string        // Node and TT statistics of the iteration, only gathered in DebugMode and sent with its first line
+}
+
+type RootMoveInfo struct {
+	Depth      int8
+	Move       Move
+	MoveNumber int           // Counts the root moves in the order they are searched at this depth, from 1
+	Time       time.Duration // Time since SearchLimits.StartTime
+}
+
+// Creates an engine with a transposition table of hashSizeMB megabytes searching with threadCount threads
+func NewEngine(hashSizeMB uint64, threadCount int) *Engine {
+	engine := &Engine{tt: NewTranspositionTable(hashSizeMB), multiPV: 1, margins: DefaultPruningMargins, iiMode: InternalIterativeReductions}
+	engine.SetThreadCount(threadCount)
+	return engine
+}
+
+// Sets the number of Lazy SMP threads, https://www.chessprogramming.org/Lazy_SMP
+// thread 0 is the main thread, every other thread is a helper that only feeds the shared transposition table
+func (engine *Engine) SetThreadCount(threadCount int) {
+	threadCount = max(1, threadCount)
+	for len(engine.threads) < threadCount {
+		engine.threads = append(engine.threads, &searchThread{id: len(engine.threads), engine: engine})
+	}
+	engine.threads = engine.threads[:threadCount]
+}
+
+func (engine *Engine) ThreadCount() int {
+	return len(engine.threads)
+}
+
+// Sets how many of the best root moves are searched and reported on, https://www.chessprogramming.org/Multi-PV
+func (engine *Engine) SetMultiPV(multiPV int) {
+	engine.multiPV = max(1, multiPV)
+}
+
+func (engine *Engine) MultiPV() int {
+	return engine.multiPV
+}
+
+// Sets the margins used from the next search on, so they can be tuned
+func (engine *Engine) SetPruningMargins(margins PruningMargins) {
+	engine.margins = margins
+}
+
+func (engine *Engine) PruningMargins() PruningMargins {
+	return engine.margins
+}
+
+// Sets how nodes without a TT move are searched from the next search on
+func (engine *Engine) SetInternalIterativeMode(mode InternalIterativeMode) {
+	engine.iiMode = mode
+}
+
+func (engine *Engine) InternalIterativeMode() InternalIterativeMode {
+	return engine.iiMode
+}
+
+// Clears the transposition table, resizing it to sizeMB megabytes
+func (engine *Engine) TTReset(board *Board, sizeMB uint64) {
+	engine.tt.TTReset(board, sizeMB)
+}
+
+// Forgets the move ordering histories of earlier searches, which are otherwise only aged from one search to the next
+func (engine *Engine) ClearHistory() {
+	for _, thread := range engine.threads {
+		thread.resetHistory()
+	}
+}
+
+// Runs the Lazy SMP search on board, returning the main thread's best move and lines along with the nodes searched by all threads.
+// The board is left as it was given once the search returns, limits that cannot be searched only set SearchResult.Err
+func (engine *Engine) Search(board *Board, limits SearchLimits) SearchResult {
+	if limits.StartTime.IsZero() {
+		limits.StartTime = time.Now()
+	}
+	if limits.Depth <= 0 || limits.Depth > MAX_SEARCH_DEPTH {
+		limits.Depth = MAX_SEARCH_DEPTH
+	}
+
+	searchMoves, err := legalSearchMoves(board, limits.SearchMoves)
+	if err != nil {
+		return SearchResult{Err: err}
+	}
+
+	if DebugMode {
+		engine.tt.TTDebugReset(board)
+	}
+
+	threads := engine.threads
+	if limits.Deterministic {
+		engine.tt.clear()
+		engine.ClearHistory()
+		threads = threads[:1]
+	}
+	engine.mateSearch = limits.Mate > 0
+	if engine.mateSearch {
+		// A mate in N is found by the side to move's Nth move, at ply 2N-1
+		limits.Depth = int8(min(int(limits.Depth), 2*int(limits.Mate)-1))
+		threads = threads[:1]
+	}
+
+	// Threads are stopped either by the caller, by the main thread running out of nodes or time, or once it has finished its last iteration
+	mainThreadDone := make(chan struct{})
+	stopped := new(atomic.Bool)
+	engine.stopped = stopped
+	engine.nodeLimit = limits.Nodes
+	engine.timeManager = limits.TimeManager
+	engine.observer = limits.Observer
+	go func() {
+		select {
+		case <-limits.CancelChannel:
+		case <-mainThreadDone:
+		}
+		stopped.Store(true) // Not engine.stopped, which may already belong to the next search by the time this runs
+	}()
+
+	var helpers sync.WaitGroup
+	for _, thread := range engine.threads {
+		thread.searchMoves = searchMoves
+		thread.nodes.Store(0)
+	}
+	// Only the threads that run get a board, helpers left out by a deterministic or mate search keep the one of their last search
+	engine.threads[0].board = board
+	for _, thread := range threads[1:] {
+		thread.board = board.DeepCopy()
+	}
+	for _, thread := range threads[1:] {
+		helpers.Add(1)
+		go func(thread *searchThread) {
+			defer helpers.Done()
+			thread.iterativeDeepening(limits.StartTime, limits.Depth)
+		}(thread)
+	}
+
+	bestMove := engine.threads[0].iterativeDeepening(limits.StartTime, limits.Depth)
+	close(mainThreadDone)
+	helpers.Wait()
+	// Stopped before the first iteration was finished, the move is not searched but at least legal
+	if bestMove == NULL_MOVE {
+		bestMove = firstRootMove(board, searchMoves)
+	}
+
+	lines := make([]PVLine, len(engine.threads[0].multiPVLines))
+	copy(lines, engine.threads[0].multiPVLines)
+	result := SearchResult{BestMove: bestMove, PonderMove: engine.ponderMove(board, bestMove), Nodes: engine.Nodes(), Time: time.Since(limits.StartTime), Lines: lines}
+	if len(lines) > 0 {
+		result.PVLine = lines[0]
+	}
+	return result
+}
+
+/*
+The reply expected after bestMove, the second move of the main thread's PV. TT cutoffs often leave the PV a single move long,
+the table's move for the position after bestMove is used then, as long as it is legal there
+*/
+func (engine *Engine) ponderMove(board *Board, bestMove Move) Move {
+	pv := engine.threads[0].savedPV
+	if bestMove == NULL_MOVE || pv[0].enc != bestMove.enc {
+		return NULL_MOVE
+	}
+	if pv[1] != NULL_MOVE {
+		return pv[1]
+	}
+
+	searchTurn := board.GetTopState().TurnCounter // Entries are stored with the turn of the search's root
+	board.MakeMove(bestMove)
+	defer board.UnMakeMove()
+	_, _, _, move, found := engine.tt.probeEntry(1, searchTurn, board.GetTopState().ZobristKey)
+	if !found || move == NULL_MOVE || !board.isLegalMove(move) {
+		return NULL_MOVE
+	}
+	return move
+}
+
+// Sums up the nodes searched by all threads during the current (or latest) search
+func (engine *Engine) Nodes() (retval uint64) {
+	for _, thread := range engine.threads {
+		retval += thread.nodes.Load()
+	}
+	return retval
+}
+
+func firstRootMove(board *Board, searchMoves []Move) Move {
+	if len(searchMoves) > 0 {
+		return searchMoves[0]
+	}
+	if moveList := board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT)); len(moveList) > 0 {
+		return moveList[0]
+	}
+	return NULL_MOVE // Checkmate or stalemate
+}
+
+// Returns the legal moves in searchMoves in the order they are generated, or an error naming the first entry that is not a legal move
+func legalSearchMoves(board *Board, searchMoves []Move) ([]Move, error) {
+	if len(searchMoves) == 0 {
+		return nil, nil
+	}
+	legalMoves := board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT))
+	for _, move := range searchMoves {
+		if !containsMove(legalMoves, move) {
+			return nil, fmt.Errorf("searchmoves move %s is not legal in this position", MoveToString(move))
+		}
+	}
+	retval := make([]Move, 0, len(searchMoves))
+	for _, move := range legalMoves {
+		if containsMove(searchMoves, move) {
+			retval = append(retval, move)
+		}
+	}
+	return retval, nil
+}
@@ -241,9 +241,10 @@ func GetPieceValue(piece PieceInfo, position Position, gamePhase int) int {
 
 }
 
+// Returns the number of moves until mate for a mate score, negative when the side to move gets mated, 0 otherwise
 func scoreIsCheckmate(score int) int {
 	if score <= MATE_SCORE+(MAX_SEARCH_DEPTH+MAX_EXTENSION_DEPTH) {
-		return ((score - MATE_SCORE) / 2) + ((score - MATE_SCORE) & 1)
+		return -(((score - MATE_SCORE) / 2) + ((score - MATE_SCORE) & 1))
 	}
 	if score >= -MATE_SCORE-(MAX_SEARCH_DEPTH+MAX_EXTENSION_DEPTH) {
 		return ((-MATE_SCORE - score) / 2) + ((-MATE_SCORE - score) & 1)
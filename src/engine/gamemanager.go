@@ -77,7 +77,7 @@ func GetGameState(board *Board) byte {
 	}
 
 	// Threefold repetition
-	if board.RepetitionPositionHistory[board.GetTopState().ZobristKey] == 3 {
+	if count, _ := board.repetitions(); count >= 2 {
 		return Repetition
 	}
 
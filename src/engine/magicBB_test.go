@@ -10,6 +10,7 @@ import (
 func TestInitTable(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	InitMagicNumber(1, 2, "temptest")
+	initMagicBB = false // Load the tables generated here even if an earlier test already loaded the real ones
 	InitMagicBitBoardTable("temptest_rook", "temptest_bishop")
 
 	if RookMask(4) != 0x1010101010106E {
@@ -32,7 +33,8 @@ func TestInitTable(t *testing.T) {
 			BitBoardToString(GetBishopMoves(12, 0x280028)), BitBoardToString(550832177192))
 	}
 
-	// Remove temporary test files
+	// Remove temporary test files, later tests load the real tables again
 	os.Remove("temptest_rook")
 	os.Remove("temptest_bishop")
+	initMagicBB = false
 }
@@ -276,6 +276,10 @@ func (board *Board) TryMoveUCI(move string) (Move, bool) {
 
 // Invariant: Assumes move is legal
 func (board *Board) MakeMove(move Move) {
+	if move == NULL_MOVE {
+		board.MakeNullMove()
+		return
+	}
 
 	from := getStartingPosition(move)
 	to := getTargetPosition(move)
@@ -306,15 +310,9 @@ func (board *Board) MakeMove(move Move) {
 		kingBitBoard = board.B.King
 	}
 
-	if move == NULL_MOVE {
-		board.pushNewState(st)
-		st.inCheck = board.isAttacked(PopLSB(&kingBitBoard), enemyColor)
-		return
-	}
-
 	piece := board.PieceInfoArr[from]
 	if piece == nil {
-		panic(fmt.Sprintf("%s begins on empty square BestMove: %s", MoveToString(move), MoveToString(pv[0])))
+		panic(fmt.Sprintf("%s begins on empty square", MoveToString(move)))
 	}
 
 	if piece.thisBitBoard == &board.B.Pawn || piece.thisBitBoard == &board.W.Pawn {
@@ -441,17 +439,46 @@ func (board *Board) MakeMove(move Move) {
 	board.pushNewState(st)
 	st.inCheck = board.isAttacked(PopLSB(&kingBitBoard), enemyColor)
 	board.updateZobristHash()
-	board.RepetitionPositionHistory[board.GetTopState().ZobristKey] += 1
+}
+
+/*
+Passes the turn without moving, used by null move pruning, https://www.chessprogramming.org/Null_Move
+Invariant: Assumes the side to move is not in check, so the side waiting cannot be in check after it either
+*/
+func (board *Board) MakeNullMove() {
+	currentState := board.GetTopState()
+
+	st := &StateInfo{
+		TurnColor:         currentState.TurnColor ^ 1,
+		HalfMoveClock:     currentState.HalfMoveClock + 1,
+		TurnCounter:       currentState.TurnCounter,
+		ZobristKey:        currentState.ZobristKey ^ zobristWhiteSideToMove,
+		CastleState:       currentState.CastleState,
+		useOpeningBook:    currentState.useOpeningBook,
+		EnPassantPosition: INVALID_POSITION, // The en passant capture is only possible right after the double push
+		PrecedentMove:     NULL_MOVE,
+	}
+	if st.TurnColor == WHITE {
+		st.TurnCounter += 1
+	}
+	if currentState.EnPassantPosition != INVALID_POSITION {
+		st.ZobristKey ^= zobristEnPassantArr[currentState.EnPassantPosition%8]
+	}
+
+	board.pushNewState(st)
+}
+
+func (board *Board) UnMakeNullMove() {
+	board.PopTopState()
 }
 
 func (board *Board) UnMakeMove() {
-	topState := board.PopTopState()
-	move := topState.PrecedentMove
-	if move == NULL_MOVE {
+	if board.GetTopState().PrecedentMove == NULL_MOVE {
+		board.UnMakeNullMove()
 		return
 	}
-
-	board.RepetitionPositionHistory[topState.ZobristKey] -= 1
+	topState := board.PopTopState()
+	move := topState.PrecedentMove
 
 	from := getStartingPosition(move)
 	to := getTargetPosition(move)
@@ -488,6 +515,7 @@ func (board *Board) UnMakeMove() {
 	// otherwise simply nil the infoarr spot as nothing exists there now
 	if captureFlag&GetFlag(move) > 0 {
 		if GetFlag(move) == epCaptureFlag {
+			board.PieceInfoArr[to] = nil // The captured pawn was not on the target square, which is empty again
 			if topState.TurnColor == WHITE {
 				board.PieceInfoArr[to+8] = topState.Capture
 				placeOnBitBoard(topState.Capture.thisBitBoard, to+8)
@@ -217,6 +217,24 @@ func Test_EnPassant(t *testing.T) {
 	if !test.equalNoStateCompare(truth) {
 		t.Fatalf("Move %d->%d with flag: %d\nWanted:\n%s\nGot:\n%s", from, to, flag, truth.DisplayBoard(), test.DisplayBoard())
 	}
+
+	// Undoing it leaves the target square empty again, for either side
+	testCases := []struct {
+		fen      string
+		from, to Position
+	}{
+		{"rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3", E5, D6},
+		{"rnbqkbnr/ppp1pppp/8/8/2Pp4/4P3/PP1P1PPP/RNBQKBNR b KQkq c3 0 3", D4, C3},
+	}
+	for _, testCase := range testCases {
+		test = InitFENBoard(testCase.fen)
+		test.MakeMove(NewMove(testCase.from, testCase.to, epCaptureFlag))
+		test.UnMakeMove()
+		truth = InitFENBoard(testCase.fen)
+		if test.PieceInfoArr[testCase.to] != nil || !test.equalNoCapture(truth) {
+			t.Fatalf("UnMakeMove %d->%d en passant\nWanted:\n%s\nGot:\n%s", testCase.from, testCase.to, truth.DisplayBoard(), test.DisplayBoard())
+		}
+	}
 }
 func Test_GetIntermediaryRay(t *testing.T) {
 	InitZobristTable()
@@ -505,3 +523,132 @@ func Test_Promotion(t *testing.T) {
 		t.Fatalf("Move %d->%d with flag: %d\nWanted:\n%s\nGot:\n%s", from, to, flag, truth.DisplayBoard(), test.DisplayBoard())
 	}
 }
+
+func Test_NullMove(t *testing.T) {
+	InitZobristTable()
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+
+	// Passing after a double push loses the en passant capture
+	test := InitFENBoard("rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3")
+	before := *test.GetTopState()
+	test.MakeNullMove()
+
+	truth := InitFENBoard("rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 1 3")
+	if test.GetTopState().ZobristKey != truth.GetTopState().ZobristKey {
+		t.Fatalf("Null move zobrist key %d, wanted %d", test.GetTopState().ZobristKey, truth.GetTopState().ZobristKey)
+	}
+	if test.GetTopState().EnPassantPosition != INVALID_POSITION || test.GetTopState().TurnColor != BLACK {
+		t.Fatalf("Null move left en passant %d and turn %d", test.GetTopState().EnPassantPosition, test.GetTopState().TurnColor)
+	}
+	if count, _ := test.repetitions(); count != 0 {
+		t.Fatalf("Null move position repeats %d positions from before the null move", count)
+	}
+
+	test.UnMakeNullMove()
+	if !test.GetTopState().Equal(&before) {
+		t.Fatalf("UnMakeNullMove did not restore the state")
+	}
+
+	// A null move through MakeMove/UnMakeMove behaves the same
+	test.MakeMove(NULL_MOVE)
+	if test.GetTopState().ZobristKey != truth.GetTopState().ZobristKey {
+		t.Fatalf("MakeMove(NULL_MOVE) zobrist key %d, wanted %d", test.GetTopState().ZobristKey, truth.GetTopState().ZobristKey)
+	}
+	test.UnMakeMove()
+	if !test.GetTopState().Equal(&before) {
+		t.Fatalf("UnMakeMove of a null move did not restore the state")
+	}
+}
+
+func Test_PlayedMove(t *testing.T) {
+	InitZobristTable()
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+
+	test := InitFENBoard("rnb1kbnr/pppp1ppp/8/4p1q1/8/5N2/PPPPPPPP/RNBQKB1R w KQkq - 0 3")
+	if _, _, ok := test.playedMove(1); ok {
+		t.Fatalf("Played move found before any move was made")
+	}
+
+	// The knight that moved two plies ago is found even though the last move captured it
+	playMove(t, test, "f3e5")
+	playMove(t, test, "g5e5")
+	if piece, to, ok := test.playedMove(1); !ok || piece != QUEEN || to != E5 {
+		t.Fatalf("Last move piece %d to %d, wanted the queen to e5", piece, to)
+	}
+	if piece, to, ok := test.playedMove(2); !ok || piece != KNIGHT || to != E5 {
+		t.Fatalf("Move before piece %d to %d, wanted the knight to e5", piece, to)
+	}
+
+	// Also when it was captured en passant
+	test = InitFENBoard("rnbqkbnr/pppp1ppp/8/4P3/8/8/PPP1PPPP/RNBQKBNR b KQkq - 0 3")
+	playMove(t, test, "d7d5")
+	playMove(t, test, "e5d6")
+	if piece, to, ok := test.playedMove(2); !ok || piece != PAWN || to != D5 {
+		t.Fatalf("Move before piece %d to %d, wanted the pawn to d5", piece, to)
+	}
+
+	test.MakeNullMove()
+	if _, _, ok := test.playedMove(1); ok {
+		t.Fatalf("Played move found after a null move")
+	}
+	if piece, to, ok := test.playedMove(2); !ok || piece != PAWN || to != D6 {
+		t.Fatalf("Move before the null move piece %d to %d, wanted the pawn to d6", piece, to)
+	}
+}
+
+func Test_Repetition(t *testing.T) {
+	InitZobristTable()
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+
+	test := InitStartBoard()
+	for _, move := range []string{"g1f3", "g8f6", "f3g1", "f6g8"} {
+		playMove(t, test, move)
+	}
+	if count, distance := test.repetitions(); count != 1 || distance != 4 {
+		t.Fatalf("Start position repeated %d times %d plies ago, wanted once 4 plies ago", count, distance)
+	}
+	// A single repetition is a draw in the search only when the first occurrence is after the root, not the root itself
+	if !test.isRepetition(5) || test.isRepetition(4) || test.isRepetition(3) {
+		t.Fatalf("Single repetition drawn at ply 5: %v, at ply 4: %v, at ply 3: %v", test.isRepetition(5), test.isRepetition(4), test.isRepetition(3))
+	}
+	if GetGameState(test) == Repetition {
+		t.Fatalf("Game drawn after a single repetition")
+	}
+
+	for _, move := range []string{"g1f3", "g8f6", "f3g1", "f6g8"} {
+		playMove(t, test, move)
+	}
+	if !test.isRepetition(0) || GetGameState(test) != Repetition {
+		t.Fatalf("Threefold repetition not detected")
+	}
+
+	// Nothing before a pawn move can be repeated
+	playMove(t, test, "e2e4")
+	for _, move := range []string{"g8f6", "g1f3", "f6g8", "f3g1"} {
+		playMove(t, test, move)
+	}
+	if count, _ := test.repetitions(); count != 0 {
+		t.Fatalf("Position after the pawn move repeated %d times", count)
+	}
+	playMove(t, test, "g8f6")
+	if count, distance := test.repetitions(); count != 1 || distance != 4 {
+		t.Fatalf("Position after the pawn move repeated %d times %d plies ago, wanted once 4 plies ago", count, distance)
+	}
+
+	// Nor anything before a null move
+	test.MakeNullMove()
+	playMove(t, test, "f6g8")
+	test.MakeNullMove()
+	playMove(t, test, "g8f6")
+	if count, _ := test.repetitions(); count != 0 {
+		t.Fatalf("Position after null moves repeated %d times", count)
+	}
+}
+
+func playMove(t *testing.T, board *Board, moveUCI string) {
+	move, ok := board.TryMoveUCI(moveUCI)
+	if !ok {
+		t.Fatalf("Could not play %s", moveUCI)
+	}
+	board.MakeMove(move)
+}
@@ -10,10 +10,6 @@ const (
 var knightMoveBoard = [64]BitBoard{}
 var kingMoveBoard = [64]BitBoard{}
 
-var friendBitboard BitBoard
-var enemyBitBoard BitBoard
-var totalBitBoard BitBoard
-
 const MAX_MOVE_COUNT = 218
 const MAX_CAPTURE_COUNT = 74
 const MAX_QUIET_COUNT = MAX_MOVE_COUNT - MAX_CAPTURE_COUNT // 144
@@ -40,8 +36,8 @@ func init() {
 	}
 }
 
-func generateSliding(thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType int, genType int, moveList *[]Move) {
-	// currentState := board.GetTopState()
+// friendBitBoard and totalBitBoard are the occupancies of the side to move and of both sides, computed once per generation
+func generateSliding(thisBitBoard, targetBitBoard, friendBitBoard, totalBitBoard BitBoard, pieceType int, genType int, moveList *[]Move) {
 	if thisBitBoard == 0 {
 		return
 	}
@@ -59,7 +55,7 @@ func generateSliding(thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType i
 		for from := PopLSB(&thisBitBoard); from != INVALID_POSITION; from = PopLSB(&thisBitBoard) {
 			validPositions := GetRookMoves(from,
 				RookMask(from)&totalBitBoard) // Possible positions &'s with total occupancy = blockers
-			validPositions &= ^friendBitboard // Removes possible captures that would be captures of friendly pieces
+			validPositions &= ^friendBitBoard // Removes possible captures that would be captures of friendly pieces
 			// this is a result of including friendly piece captures in potential moves for faster magic BB's
 			validPositions &= targetBitBoard
 			for to := PopLSB(&validPositions); to != INVALID_POSITION; to = PopLSB(&validPositions) {
@@ -71,7 +67,7 @@ func generateSliding(thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType i
 		for from := PopLSB(&thisBitBoard); from != INVALID_POSITION; from = PopLSB(&thisBitBoard) {
 			validPositions := GetBishopMoves(from,
 				BishopMask(from)&totalBitBoard) // Possible positions &'s with total occupancy = blockers
-			validPositions &= ^friendBitboard // Removes possible captures that would be captures of friendly pieces
+			validPositions &= ^friendBitBoard // Removes possible captures that would be captures of friendly pieces
 			// this is a result of including friendly piece captures in potential moves for faster magic BB's
 			validPositions &= targetBitBoard
 			for to := PopLSB(&validPositions); to != INVALID_POSITION; to = PopLSB(&validPositions) {
@@ -86,7 +82,7 @@ func generateSliding(thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType i
 			bishopMoves := GetBishopMoves(from,
 				BishopMask(from)&totalBitBoard)
 			// queen = rook + bishop!
-			validPositions := (rookMoves | bishopMoves) & ^friendBitboard & targetBitBoard
+			validPositions := (rookMoves | bishopMoves) & ^friendBitBoard & targetBitBoard
 			for to := PopLSB(&validPositions); to != INVALID_POSITION; to = PopLSB(&validPositions) {
 				*moveList = append(*moveList, NewMove(from, to, flag))
 			}
@@ -95,7 +91,7 @@ func generateSliding(thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType i
 
 }
 
-func generateNonSliding(board *Board, thisBitBoard BitBoard, targetBitBoard BitBoard, pieceType int, genType int, moveList *[]Move) {
+func generateNonSliding(board *Board, thisBitBoard, targetBitBoard, totalBitBoard BitBoard, pieceType int, genType int, moveList *[]Move) {
 	// Current state of board, includes who's turn it is, any EnPassant possibility, along with Castling Rights
 	currentState := board.GetTopState()
 	if thisBitBoard == 0 {
@@ -136,12 +132,12 @@ func generateNonSliding(board *Board, thisBitBoard BitBoard, targetBitBoard BitB
 				eastCaptureEnPassant = Shift(thisBitBoard&^Col8Full, pawnPushDirection+E) & (1 << currentState.EnPassantPosition) // If so see if
 				westCaptureEnPassant = Shift(thisBitBoard&^Col1Full, pawnPushDirection+W) & (1 << currentState.EnPassantPosition) // en passant exists on capture spots
 				if eastCaptureEnPassant != 0 {
-					if !board.epDoublePinFix(currentState.EnPassantPosition, -pawnPushDirection, -(pawnPushDirection + E), currentState.TurnColor) {
+					if !board.epDoublePinFix(currentState.EnPassantPosition, -pawnPushDirection, -(pawnPushDirection + E), currentState.TurnColor, totalBitBoard) {
 						moveListHelper(eastCaptureEnPassant, pawnPushDirection+E, moveList, epCaptureFlag)
 					}
 				}
 				if westCaptureEnPassant != 0 {
-					if !board.epDoublePinFix(currentState.EnPassantPosition, -pawnPushDirection, -(pawnPushDirection + W), currentState.TurnColor) {
+					if !board.epDoublePinFix(currentState.EnPassantPosition, -pawnPushDirection, -(pawnPushDirection + W), currentState.TurnColor, totalBitBoard) {
 						moveListHelper(westCaptureEnPassant, pawnPushDirection+W, moveList, epCaptureFlag)
 					}
 				}
@@ -195,7 +191,7 @@ func generateNonSliding(board *Board, thisBitBoard BitBoard, targetBitBoard BitB
 	}
 }
 
-func enemyPieceAttackBitBoard(board *Board) (retval BitBoard) {
+func enemyPieceAttackBitBoard(board *Board, totalBitBoard BitBoard) (retval BitBoard) {
 	// Current state of board
 	currentState := board.GetTopState()
 	var enemyPieces Pieces
@@ -263,7 +259,7 @@ func enemyPieceAttackBitBoard(board *Board) (retval BitBoard) {
 	return retval
 }
 
-func generateKing(board *Board, targetBitBoard BitBoard, genType int, inCheck bool, moveList *[]Move) {
+func generateKing(board *Board, targetBitBoard, totalBitBoard BitBoard, genType int, inCheck bool, moveList *[]Move) {
 	// Current state of board, includes who's turn it is, any EnPassant possibility, along with Castling Rights
 	currentState := board.GetTopState()
 
@@ -324,6 +320,7 @@ goes into the checkers listif only 1 friendly piece, then add that piece to pinn
 []PieceInfo if 2 or more, then ignore the sliding piece!
 */
 func generateCheck(board *Board) (pinnedPieces *[]PinnedPieceInfo, pinnedPiecesBitBoard BitBoard, checkingPieces *[]CheckerInfo) {
+	friendBitBoard, enemyBitBoard := board.sideOccupancy()
 	// Current state of board, includes who's turn it is, any EnPassant possibility, along with Castling Rights
 	currentState := board.GetTopState()
 
@@ -391,7 +388,7 @@ func generateCheck(board *Board) (pinnedPieces *[]PinnedPieceInfo, pinnedPiecesB
 			checkRay := getIntermediaryRay(from, to)
 			checkerPieceInfo := CheckerInfo{*board.PieceInfoArr[to], to, checkRay}
 
-			checkRayPinned := checkRay & friendBitboard
+			checkRayPinned := checkRay & friendBitBoard
 			friendlyPinnedPosition := PopLSB(&checkRayPinned)
 
 			if friendlyPinnedPosition == INVALID_POSITION { // No pinned pieces -> + to CheckerInfo
@@ -419,7 +416,7 @@ func generateCheck(board *Board) (pinnedPieces *[]PinnedPieceInfo, pinnedPiecesB
 			checkRay := getIntermediaryRay(from, to)
 			checkerPieceInfo := CheckerInfo{*board.PieceInfoArr[to], to, checkRay}
 
-			checkRayPinned := checkRay & friendBitboard
+			checkRayPinned := checkRay & friendBitBoard
 			friendlyPinnedPosition := PopLSB(&checkRayPinned)
 
 			if friendlyPinnedPosition == INVALID_POSITION { // No pinned pieces -> + to CheckerInfo
@@ -441,7 +438,7 @@ func generateCheck(board *Board) (pinnedPieces *[]PinnedPieceInfo, pinnedPiecesB
 	return pinnedPieces, pinnedPiecesBitBoard, checkingPieces
 }
 
-func generatePinned(board *Board, genType int, pinnedPieces *[]PinnedPieceInfo, moveList *[]Move) {
+func generatePinned(board *Board, friendBitBoard, totalBitBoard BitBoard, genType int, pinnedPieces *[]PinnedPieceInfo, moveList *[]Move) {
 	for _, pinnedPiece := range *pinnedPieces {
 		thisBitBoard := BitBoard(1) << pinnedPiece.position
 		var targetBitBoard BitBoard
@@ -455,29 +452,30 @@ func generatePinned(board *Board, genType int, pinnedPieces *[]PinnedPieceInfo,
 
 		switch pinnedPiece.pieceInfo.pieceTYPE {
 		case PAWN:
-			generateNonSliding(board, thisBitBoard, targetBitBoard, PAWN, genType, moveList)
+			generateNonSliding(board, thisBitBoard, targetBitBoard, totalBitBoard, PAWN, genType, moveList)
 		case KNIGHT:
-			generateNonSliding(board, thisBitBoard, targetBitBoard, KNIGHT, genType, moveList)
+			generateNonSliding(board, thisBitBoard, targetBitBoard, totalBitBoard, KNIGHT, genType, moveList)
 		case ROOK:
-			generateSliding(thisBitBoard, targetBitBoard, ROOK, genType, moveList)
+			generateSliding(thisBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, ROOK, genType, moveList)
 		case BISHOP:
-			generateSliding(thisBitBoard, targetBitBoard, BISHOP, genType, moveList)
+			generateSliding(thisBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, BISHOP, genType, moveList)
 		case QUEEN:
-			generateSliding(thisBitBoard, targetBitBoard, QUEEN, genType, moveList)
+			generateSliding(thisBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, QUEEN, genType, moveList)
 		}
 	}
 }
 
+/*
+Appends the legal moves of genType to moveList:
+  - CAPTURE, captures and capture promotions
+  - QUIET, every other move
+  - ALL, captures followed by quiet moves
+  - EVASION, every way out of check (capturing the checker, blocking it or moving the king), nothing when not in check
+*/
 func (board *Board) GenerateMoves(genType int, moveList []Move) []Move {
-	if genType == ALL {
-		moveList = board.GenerateMoves(CAPTURE, moveList)
-		moveList = board.GenerateMoves(QUIET, moveList)
-		return moveList
-	}
 	currentState := board.GetTopState()
 
 	var friendlyPieces, enemyPieces *Pieces
-	var targetBitBoard BitBoard
 
 	if currentState.TurnColor == WHITE {
 		enemyPieces = &board.B
@@ -491,11 +489,26 @@ func (board *Board) GenerateMoves(genType int, moveList []Move) []Move {
 		return moveList
 	}
 
-	friendBitboard = friendlyPieces.OccupancyBitBoard()
-	enemyBitBoard = enemyPieces.OccupancyBitBoard()
-	totalBitBoard = friendBitboard | enemyBitBoard
-
 	pinnedPieces, pinnedPiecesBitBoard, checkingPieces := generateCheck(board)
+
+	switch genType {
+	case EVASION:
+		if len(*checkingPieces) == 0 {
+			return moveList
+		}
+		fallthrough // In check every legal move is an evasion, the checkers already limit the targets of both generation types
+	case ALL:
+		moveList = board.generateMoves(CAPTURE, friendlyPieces, enemyPieces, pinnedPieces, pinnedPiecesBitBoard, checkingPieces, moveList)
+		return board.generateMoves(QUIET, friendlyPieces, enemyPieces, pinnedPieces, pinnedPiecesBitBoard, checkingPieces, moveList)
+	}
+	return board.generateMoves(genType, friendlyPieces, enemyPieces, pinnedPieces, pinnedPiecesBitBoard, checkingPieces, moveList)
+}
+
+// Generates the CAPTURE or QUIET moves once the checkers and pinned pieces of the side to move are known
+func (board *Board) generateMoves(genType int, friendlyPieces, enemyPieces *Pieces, pinnedPieces *[]PinnedPieceInfo, pinnedPiecesBitBoard BitBoard, checkingPieces *[]CheckerInfo, moveList []Move) []Move {
+	var targetBitBoard BitBoard
+	friendBitBoard, enemyBitBoard := friendlyPieces.OccupancyBitBoard(), enemyPieces.OccupancyBitBoard()
+	totalBitBoard := friendBitBoard | enemyBitBoard
 	inCheck := len(*checkingPieces) > 0
 
 	switch len(*checkingPieces) {
@@ -507,12 +520,12 @@ func (board *Board) GenerateMoves(genType int, moveList []Move) []Move {
 		case QUIET:
 			targetBitBoard = ^totalBitBoard
 		}
-		generatePinned(board, genType, pinnedPieces, &moveList)
-		generateSliding(friendlyPieces.Queen&^pinnedPiecesBitBoard, targetBitBoard, QUEEN, genType, &moveList)
-		generateSliding(friendlyPieces.Bishop&^pinnedPiecesBitBoard, targetBitBoard, BISHOP, genType, &moveList)
-		generateSliding(friendlyPieces.Rook&^pinnedPiecesBitBoard, targetBitBoard, ROOK, genType, &moveList)
-		generateNonSliding(board, friendlyPieces.Knight&^pinnedPiecesBitBoard, targetBitBoard, KNIGHT, genType, &moveList)
-		generateNonSliding(board, friendlyPieces.Pawn&^pinnedPiecesBitBoard, targetBitBoard, PAWN, genType, &moveList)
+		generatePinned(board, friendBitBoard, totalBitBoard, genType, pinnedPieces, &moveList)
+		generateSliding(friendlyPieces.Queen&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, QUEEN, genType, &moveList)
+		generateSliding(friendlyPieces.Bishop&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, BISHOP, genType, &moveList)
+		generateSliding(friendlyPieces.Rook&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, ROOK, genType, &moveList)
+		generateNonSliding(board, friendlyPieces.Knight&^pinnedPiecesBitBoard, targetBitBoard, totalBitBoard, KNIGHT, genType, &moveList)
+		generateNonSliding(board, friendlyPieces.Pawn&^pinnedPiecesBitBoard, targetBitBoard, totalBitBoard, PAWN, genType, &moveList)
 	case 1:
 		// 1 Checker, quiet target bitboard is intermediary ray,
 		// capture target bitboard is the checker
@@ -522,23 +535,25 @@ func (board *Board) GenerateMoves(genType int, moveList []Move) []Move {
 		case QUIET:
 			targetBitBoard = (*checkingPieces)[0].intermediaryRay
 		}
-		generateSliding(friendlyPieces.Queen&^pinnedPiecesBitBoard, targetBitBoard, QUEEN, genType, &moveList)
-		generateSliding(friendlyPieces.Bishop&^pinnedPiecesBitBoard, targetBitBoard, BISHOP, genType, &moveList)
-		generateSliding(friendlyPieces.Rook&^pinnedPiecesBitBoard, targetBitBoard, ROOK, genType, &moveList)
-		generateNonSliding(board, friendlyPieces.Knight&^pinnedPiecesBitBoard, targetBitBoard, KNIGHT, genType, &moveList)
-		generateNonSliding(board, friendlyPieces.Pawn&^pinnedPiecesBitBoard, targetBitBoard, PAWN, genType, &moveList)
+		generateSliding(friendlyPieces.Queen&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, QUEEN, genType, &moveList)
+		generateSliding(friendlyPieces.Bishop&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, BISHOP, genType, &moveList)
+		generateSliding(friendlyPieces.Rook&^pinnedPiecesBitBoard, targetBitBoard, friendBitBoard, totalBitBoard, ROOK, genType, &moveList)
+		generateNonSliding(board, friendlyPieces.Knight&^pinnedPiecesBitBoard, targetBitBoard, totalBitBoard, KNIGHT, genType, &moveList)
+		generateNonSliding(board, friendlyPieces.Pawn&^pinnedPiecesBitBoard, targetBitBoard, totalBitBoard, PAWN, genType, &moveList)
 
 	}
 	switch genType {
 	case CAPTURE:
 		generateKing(board,
-			enemyBitBoard & ^enemyPieceAttackBitBoard(board),
+			enemyBitBoard & ^enemyPieceAttackBitBoard(board, totalBitBoard),
+			totalBitBoard,
 			CAPTURE,
 			inCheck,
 			&moveList)
 	case QUIET:
 		generateKing(board,
-			^(totalBitBoard) & ^enemyPieceAttackBitBoard(board),
+			^(totalBitBoard) & ^enemyPieceAttackBitBoard(board, totalBitBoard),
+			totalBitBoard,
 			QUIET,
 			inCheck,
 			&moveList)
@@ -632,13 +647,210 @@ func (board *Board) isAttacked(position Position, color int8) bool {
 	return false
 }
 
+/*
+Checks whether move is legal in the current position without generating any moves.
+Used for moves that come from elsewhere: the TT move may come from another position through a key collision,
+and killers and counter moves were found in sibling positions
+*/
+func (board *Board) isLegalMove(move Move) bool {
+	if move == NULL_MOVE {
+		return false
+	}
+	currentState := board.GetTopState()
+	from, to, flag := getStartingPosition(move), getTargetPosition(move), GetFlag(move)
+	piece := board.PieceInfoArr[from]
+	if piece == nil || piece.color != currentState.TurnColor {
+		return false
+	}
+
+	friendBitBoard, enemyBitBoard := board.sideOccupancy()
+	occupied := friendBitBoard | enemyBitBoard
+	fromBitBoard, toBitBoard := BitBoard(1)<<from, BitBoard(1)<<to
+
+	// What stands on the target square has to match the flag
+	capturedBitBoard := toBitBoard
+	switch {
+	case flag == epCaptureFlag:
+		if piece.pieceTYPE != PAWN || to != currentState.EnPassantPosition {
+			return false
+		}
+		if currentState.TurnColor == WHITE {
+			capturedBitBoard = toBitBoard >> 8
+		} else {
+			capturedBitBoard = toBitBoard << 8
+		}
+	case flag&captureFlag != 0:
+		if toBitBoard&enemyBitBoard == 0 || board.PieceInfoArr[to].pieceTYPE == KING {
+			return false
+		}
+	default:
+		if toBitBoard&occupied != 0 {
+			return false
+		}
+		capturedBitBoard = 0
+	}
+	// Only pawns promote, and they always do on the last rank
+	if (flag&0b1000 != 0) != (piece.pieceTYPE == PAWN && toBitBoard&PromotionFull != 0) {
+		return false
+	}
+
+	switch piece.pieceTYPE {
+	case PAWN:
+		push, startRow := int(N), Row2Full
+		if currentState.TurnColor == BLACK {
+			push, startRow = int(S), Row7Full
+		}
+		switch flag {
+		case quietFlag, knightPromotionFlag, rookPromotionFlag, bishopPromotionFlag, queenPromotionFlag:
+			if int(to) != int(from)+push {
+				return false
+			}
+		case doublePawnPushFlag:
+			if fromBitBoard&startRow == 0 || int(to) != int(from)+2*push || occupied&(BitBoard(1)<<(int(from)+push)) != 0 {
+				return false
+			}
+		case captureFlag, epCaptureFlag, knightPromoCaptureFlag, rookPromoCaptureFlag, bishopPromoCaptureFlag, queenPromoCaptureFlag:
+			if board.attackersTo(to, occupied)&fromBitBoard == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	case KING:
+		if flag == kingCastleFlag || flag == queenCastleFlag {
+			return board.isLegalCastle(from, to, flag, occupied, enemyBitBoard)
+		}
+		fallthrough
+	default:
+		if (flag != quietFlag && flag != captureFlag) || board.attackersTo(to, occupied)&fromBitBoard == 0 {
+			return false
+		}
+	}
+
+	// Finally the move may not leave the own king attacked
+	kingBitBoard := friendBitBoard & (board.W.King | board.B.King)
+	if piece.pieceTYPE == KING {
+		kingBitBoard = toBitBoard
+	}
+	occupiedAfter := occupied&^fromBitBoard&^capturedBitBoard | toBitBoard
+	return board.attackersTo(PopLSB(&kingBitBoard), occupiedAfter)&enemyBitBoard&^capturedBitBoard == 0
+}
+
+// Castling needs its right, an empty path between king and rook, and no check on the squares the king starts on and passes
+func (board *Board) isLegalCastle(from, to Position, flag Flag, occupied, enemyBitBoard BitBoard) bool {
+	currentState := board.GetTopState()
+	if currentState.inCheck {
+		return false
+	}
+	var right bool
+	var path BitBoard // Squares between king and rook
+	var passed [2]Position
+	if flag == kingCastleFlag {
+		right = currentState.getCastleWKing()
+		if currentState.TurnColor == BLACK {
+			right = currentState.getCastleBKing()
+		}
+		if int(to) != int(from)+2 {
+			return false
+		}
+		path = getIntermediaryRay(from, from+3)
+		passed = [2]Position{from + 1, from + 2}
+	} else {
+		right = currentState.getCastleWQueen()
+		if currentState.TurnColor == BLACK {
+			right = currentState.getCastleBQueen()
+		}
+		if int(to) != int(from)-2 {
+			return false
+		}
+		path = getIntermediaryRay(from, from-4)
+		passed = [2]Position{from - 1, from - 2}
+	}
+	if !right || path&occupied != 0 {
+		return false
+	}
+	// The king is taken off the board, so it cannot hide a square behind it from a slider
+	occupied &^= BitBoard(1) << from
+	for _, square := range passed {
+		if board.attackersTo(square, occupied)&enemyBitBoard != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether a legal move would put the enemy king in check, directly or by uncovering a slider, without making it
+func (board *Board) givesCheck(move Move) bool {
+	flag := GetFlag(move)
+	if flag == kingCastleFlag || flag == queenCastleFlag { // The rook gives the check, rare enough to just play it out
+		board.MakeMove(move)
+		defer board.UnMakeMove()
+		return board.InCheck()
+	}
+
+	from := getStartingPosition(move)
+	to := getTargetPosition(move)
+	color := board.GetTopState().TurnColor
+	friendlyPieces, enemyPieces := &board.W, &board.B
+	if color == BLACK {
+		friendlyPieces, enemyPieces = &board.B, &board.W
+	}
+	kingBitBoard := enemyPieces.King
+	kingPosition := PopLSB(&kingBitBoard)
+	kingBitBoard = BitBoard(1) << kingPosition
+
+	fromBitBoard := BitBoard(1) << from
+	toBitBoard := BitBoard(1) << to
+	occupied := (board.W.OccupancyBitBoard()|board.B.OccupancyBitBoard())&^fromBitBoard | toBitBoard
+	if flag == epCaptureFlag {
+		if color == WHITE {
+			occupied &^= toBitBoard >> 8
+		} else {
+			occupied &^= toBitBoard << 8
+		}
+	}
+
+	pieceType := board.PieceInfoArr[from].pieceTYPE
+	if flag&0b1000 > 0 {
+		pieceType = [4]int{KNIGHT, ROOK, BISHOP, QUEEN}[flag&0b11]
+	}
+
+	// Direct check from the target square
+	var attacks BitBoard
+	switch pieceType {
+	case PAWN:
+		if color == WHITE {
+			attacks = (Shift(toBitBoard, N+E) &^ Col1Full) | (Shift(toBitBoard, N+W) &^ Col8Full)
+		} else {
+			attacks = (Shift(toBitBoard, S+E) &^ Col1Full) | (Shift(toBitBoard, S+W) &^ Col8Full)
+		}
+	case KNIGHT:
+		attacks = knightMoveBoard[to]
+	case BISHOP:
+		attacks = GetBishopMoves(to, BishopMask(to)&occupied)
+	case ROOK:
+		attacks = GetRookMoves(to, RookMask(to)&occupied)
+	case QUEEN:
+		attacks = GetBishopMoves(to, BishopMask(to)&occupied) | GetRookMoves(to, RookMask(to)&occupied)
+	}
+	if attacks&kingBitBoard != 0 {
+		return true
+	}
+
+	// Discovered check by a slider that was behind the moving piece
+	rookLikes := (friendlyPieces.Rook | friendlyPieces.Queen) &^ fromBitBoard
+	bishopLikes := (friendlyPieces.Bishop | friendlyPieces.Queen) &^ fromBitBoard
+	return GetRookMoves(kingPosition, RookMask(kingPosition)&occupied)&rookLikes != 0 ||
+		GetBishopMoves(kingPosition, BishopMask(kingPosition)&occupied)&bishopLikes != 0
+}
+
 // Fixes double pawn pin illegality with en-passant capture
 // example position is
 //
 //	FEN:
 //
 // 8/8/1B3b2/4p3/4QPpk/3P4/6p1/4R1K1 b - f3 0 52
-func (board *Board) epDoublePinFix(enPassantPosition Position, dirOfCaptured, dirOfCapturer Direction, color int8) bool {
+func (board *Board) epDoublePinFix(enPassantPosition Position, dirOfCaptured, dirOfCapturer Direction, color int8, totalBitBoard BitBoard) bool {
 	capturedSpotBitBoard := BitBoard(1) << enPassantPosition
 	involvedPawns := Shift(capturedSpotBitBoard, dirOfCaptured) | Shift(capturedSpotBitBoard, dirOfCapturer)
 
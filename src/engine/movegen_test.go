@@ -2,6 +2,7 @@ package chessengine
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -165,3 +166,112 @@ func Test_Position_doubleEnPassantPawnPin(t *testing.T) {
 	}
 	fmt.Printf("Speed: %d Nodes/sec", 1000*uint64(float64(perftOut)/float64(time.Now().UnixMilli()-startTime)))
 }
+
+// Runs perft on several boards at once, run with -race to check that move generation shares no state
+func Test_ConcurrentPerft(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	testCases := []struct {
+		fen      string
+		depth    int
+		expected uint64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 4, 197281},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+		{"3r1k2/4npp1/1ppr3p/p6P/P2PPPP1/1NR5/5K2/2R5 w - - 0 1", 3, 26013},
+		{"8/8/1B3b2/4p3/4QPpk/3P4/6p1/4R1K1 b - f3 0 52", 4, 129973},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 4, 197281},
+	}
+
+	results := make([]uint64, len(testCases))
+	var wg sync.WaitGroup
+	for i, testCase := range testCases {
+		wg.Add(1)
+		go func(i int, fen string, depth int) {
+			defer wg.Done()
+			results[i], _ = Perft(InitFENBoard(fen), depth, true)
+		}(i, testCase.fen, testCase.depth)
+	}
+	wg.Wait()
+
+	for i, testCase := range testCases {
+		if results[i] != testCase.expected {
+			t.Fatalf("Concurrent Perft(%s, %d) failed\n\texpected: %d\n\tgot: %d\n", testCase.fen, testCase.depth, testCase.expected, results[i])
+		}
+	}
+}
+
+// Walks the tree below fen and checks that in check EVASION generates exactly the legal moves, and nothing otherwise
+func Test_Evasions(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/8/1B3b2/4p3/4QPpk/3P4/6p1/4R1K1 b - f3 0 52",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+
+	var walk func(board *Board, depth int)
+	walk = func(board *Board, depth int) {
+		allMoves := board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT))
+		evasions := board.GenerateMoves(EVASION, make([]Move, 0, MAX_MOVE_COUNT))
+		if !board.InCheck() && len(evasions) != 0 {
+			t.Fatalf("%d evasions generated without check\n%s", len(evasions), board.DisplayBoard())
+		}
+		if board.InCheck() {
+			if len(evasions) != len(allMoves) {
+				t.Fatalf("%d evasions generated, wanted %d\n%s", len(evasions), len(allMoves), board.DisplayBoard())
+			}
+			for _, move := range allMoves {
+				if !containsMove(evasions, move) {
+					t.Fatalf("Evasion %s missing\n%s", MoveToString(move), board.DisplayBoard())
+				}
+			}
+		}
+		if depth == 0 {
+			return
+		}
+		for _, move := range allMoves {
+			board.MakeMove(move)
+			walk(board, depth-1)
+			board.UnMakeMove()
+		}
+	}
+
+	for _, fen := range fens {
+		walk(InitFENBoard(fen), 3)
+	}
+}
+
+// givesCheck has to agree with making the move for every legal move below fen
+func Test_GivesCheck(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/8/1B3b2/4p3/4QPpk/3P4/6p1/4R1K1 b - f3 0 52",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+
+	var walk func(board *Board, depth int)
+	walk = func(board *Board, depth int) {
+		for _, move := range board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT)) {
+			givesCheck := board.givesCheck(move)
+			board.MakeMove(move)
+			if givesCheck != board.InCheck() {
+				board.UnMakeMove()
+				t.Fatalf("givesCheck(%s) = %t\n%s", MoveToString(move), givesCheck, board.DisplayBoard())
+			}
+			if depth > 0 {
+				walk(board, depth-1)
+			}
+			board.UnMakeMove()
+		}
+	}
+
+	for _, fen := range fens {
+		walk(InitFENBoard(fen), 2)
+	}
+}
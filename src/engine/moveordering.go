@@ -2,7 +2,6 @@ package chessengine
 
 import (
 	"cmp"
-	"fmt"
 	"slices"
 )
 
@@ -16,6 +15,8 @@ const (
 
 const CAPTURE_OFFSET int16 = 1000 // Used for CAPTURE ORDERING
 
+const BAD_CAPTURE_OFFSET int16 = -2000 // Captures losing material go after every quiet move, whose history score never gets this low
+
 // BASIC MVV_LVA constants
 // var basic_mvv_lvaTable = [30]int16{
 // 	19, 11, 9, 8, 1, 0, // victim P, attacker P, N, B, R, Q, K
@@ -49,9 +50,6 @@ var blind_mvv_lvaTable = [30]int16{
 
 const BLIND_TABLE_ROW_SIZE int = 6
 
-// History heuristic variables
-var history [2][6][64]int16
-
 // History heuristic constants
 const (
 	HISTORY_MULTIPLIER     int16 = 32
@@ -59,11 +57,26 @@ const (
 	HISTORY_MAX_HISTORY    int16 = 31 * 31
 )
 
-// Killer heuristic variables
-var killerMoves [MAX_POSSIBLE_DEPTH][2]Move
-var killerMovesCounter [MAX_POSSIBLE_DEPTH][64][64]uint16
-
 const KILLER_MOVE_SCORE int16 = 997
+const COUNTER_MOVE_SCORE int16 = KILLER_MOVE_SCORE - 1 // Above every history score, below the killers
+
+const CAPTURE_HISTORY_DIVISOR int16 = 64 // Capture history only reorders captures within about the same MVV-LVA class
+
+// Continuation history, https://www.chessprogramming.org/History_Heuristic#History_Extensions
+// indexed by side to move, piece and target of an earlier move, piece and target of the move
+type continuationHistory [2][6][64][6][64]int16
+
+/*
+The history tables a quiet move is scored and updated with at the current node,
+they depend on the moves that led to it so they are looked up once per node.
+Pointers are nil when the move they depend on was a null move or was played before the game started
+*/
+type historyContext struct {
+	side            int8
+	counterMove     *Move         // Reply that last refuted the opponent's previous move, https://www.chessprogramming.org/Countermove_Heuristic
+	counterHistory  *[6][64]int16 // 1-ply continuation history, continuing the opponent's previous move
+	followUpHistory *[6][64]int16 // 2-ply continuation history, continuing our own previous move
+}
 
 func init() {
 	// for i := range basic_mvv_lvaTable {
@@ -95,39 +108,50 @@ For example, a very promising move, when available, is a move where one player m
 the opponent’s queen with their own pawn and this move is thus given the highest priority
 by the MVV-LVA heuristic.
 */
-func (board *Board) moveordering(PVMove Move, TTMove Move, plyFromRoot int8, moveList []Move) {
+func (thread *searchThread) moveordering(PVMove Move, TTMove Move, plyFromRoot int8, moveList []Move) {
+	history := thread.historyContext()
 	for i := range moveList {
-		if moveList[i].enc == PVMove.enc {
+		switch moveList[i].enc {
+		case PVMove.enc:
 			moveList[i].priority = PV_MOVE_SCORE
-			continue
-		}
-		if moveList[i].enc == TTMove.enc {
+		case TTMove.enc:
 			moveList[i].priority = TT_MOVE_SCORE
-			continue
+		default:
+			moveList[i].priority = thread.scoreMove(moveList[i], plyFromRoot, &history)
 		}
+	}
+	sortMoves(moveList)
+}
 
-		switch GetFlag(moveList[i]) {
-		case captureFlag:
-			moveList[i].priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(moveList[i])].pieceTYPE,
-				board.PieceInfoArr[getTargetPosition(moveList[i])].pieceTYPE, getTargetPosition(moveList[i]))
-		case epCaptureFlag:
-			moveList[i].priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(moveList[i])].pieceTYPE,
-				PAWN, getTargetPosition(moveList[i]))
-		case knightPromotionFlag, bishopPromotionFlag, rookPromotionFlag, knightPromoCaptureFlag, bishopPromoCaptureFlag, rookPromoCaptureFlag:
-			moveList[i].priority = NORMAL_PROMO_SCORE // Promotions are always good
-			continue
-		case queenPromotionFlag, queenPromoCaptureFlag:
-			moveList[i].priority = QUEEN_PROMO_SCORE // Normally queen promo = best promo
-			continue
-		default: // Quiet moves
-			moveList[i].priority = getKiller(plyFromRoot, moveList[i])
-			if moveList[i].priority == 0 {
-				moveList[i].priority = getHistory(board, moveList[i])
-			}
+// Ordering priority of a move that is neither the PV nor the TT move
+func (thread *searchThread) scoreMove(move Move, plyFromRoot int8, history *historyContext) (priority int16) {
+	board := thread.board
+	switch GetFlag(move) {
+	case captureFlag:
+		priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(move)].pieceTYPE,
+			board.PieceInfoArr[getTargetPosition(move)].pieceTYPE, board.SEE(move))
+		return priority + thread.getCaptureHistory(move)/CAPTURE_HISTORY_DIVISOR
+	case epCaptureFlag:
+		priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(move)].pieceTYPE,
+			PAWN, board.SEE(move))
+		return priority + thread.getCaptureHistory(move)/CAPTURE_HISTORY_DIVISOR
+	case knightPromotionFlag, bishopPromotionFlag, rookPromotionFlag, knightPromoCaptureFlag, bishopPromoCaptureFlag, rookPromoCaptureFlag:
+		return NORMAL_PROMO_SCORE // Promotions are always good
+	case queenPromotionFlag, queenPromoCaptureFlag:
+		return QUEEN_PROMO_SCORE // Normally queen promo = best promo
+	default: // Quiet moves
+		if priority = thread.getKiller(plyFromRoot, move); priority != 0 {
+			return priority
 		}
-
+		if history.counterMove != nil && move.enc == history.counterMove.enc {
+			return COUNTER_MOVE_SCORE
+		}
+		return thread.getQuietHistory(history, move)
 	}
+}
 
+// Sorts moves by descending priority
+func sortMoves(moveList []Move) {
 	slices.SortFunc(moveList, func(a, b Move) int {
 		return cmp.Compare(b.priority, a.priority)
 	})
@@ -138,18 +162,18 @@ func (board *Board) quiescence_moveordering(moveList []Move) {
 		switch GetFlag(moveList[i]) {
 		case captureFlag:
 			moveList[i].priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(moveList[i])].pieceTYPE,
-				board.PieceInfoArr[getTargetPosition(moveList[i])].pieceTYPE, getTargetPosition(moveList[i]))
+				board.PieceInfoArr[getTargetPosition(moveList[i])].pieceTYPE, board.SEE(moveList[i]))
 		case epCaptureFlag:
 			moveList[i].priority = board.mvv_lva_score(board.PieceInfoArr[getStartingPosition(moveList[i])].pieceTYPE,
-				PAWN, getTargetPosition(moveList[i]))
-		case knightPromoCaptureFlag, bishopPromoCaptureFlag, rookPromoCaptureFlag:
+				PAWN, board.SEE(moveList[i]))
+		case knightPromotionFlag, bishopPromotionFlag, rookPromotionFlag, knightPromoCaptureFlag, bishopPromoCaptureFlag, rookPromoCaptureFlag:
 			moveList[i].priority = NORMAL_PROMO_SCORE // Promotions are always good
 			continue
-		case queenPromoCaptureFlag:
+		case queenPromotionFlag, queenPromoCaptureFlag:
 			moveList[i].priority = QUEEN_PROMO_SCORE // Normally queen promo = best promo
 			continue
-		default: // Quiet Moves
-			panic("Quiessence move ordering should only be used for captures, but this flag is not a capture!")
+		default: // Quiet Moves, only evasions and checks reach the quiescence search, they go between the good and the bad captures
+			moveList[i].priority = 0
 		}
 	}
 
@@ -158,92 +182,200 @@ func (board *Board) quiescence_moveordering(moveList []Move) {
 	})
 }
 
-func (board *Board) mvv_lva_score(aggressorPieceType, victimPieceType int, capturePosition Position) (priority int16) {
-	if board.isAttacked(capturePosition, board.GetTopState().TurnColor^1) {
-		// if (victimPieceType*MVV_LVA_TABLE_ROW_SIZE)+aggressorPieceType > len(basic_mvv_lvaTable) {
-		// 	fmt.Println(board.DisplayBoard())
-		// 	panic("wtf")
-		// }
-		return mvv_lvaTable[(victimPieceType*MVV_LVA_TABLE_ROW_SIZE)+aggressorPieceType]
-	} else { /* BLIND (Better or Lesser If Not Defended) CAPTURES, if the victim is undefended, then the priority is increased */
+// Orders a capture by MVV-LVA within its class, winning captures first, then even trades, and losing captures after the quiet moves
+func (board *Board) mvv_lva_score(aggressorPieceType, victimPieceType int, see int) (priority int16) {
+	switch {
+	case see > 0: /* BLIND (Better or Lesser If Not Defended) CAPTURES, if the capture wins material, then the priority is increased */
 		return blind_mvv_lvaTable[(victimPieceType*BLIND_TABLE_ROW_SIZE)+aggressorPieceType]
+	case see == 0: // Legal king captures are never defended, so only the other pieces end up here and below
+		return mvv_lvaTable[(victimPieceType*MVV_LVA_TABLE_ROW_SIZE)+aggressorPieceType]
+	default:
+		return BAD_CAPTURE_OFFSET + mvv_lvaTable[(victimPieceType*MVV_LVA_TABLE_ROW_SIZE)+aggressorPieceType] - CAPTURE_OFFSET
 	}
 	// return basic_mvv_lvaTable[(victimPieceType*BASIC_MVV_TABLE_ROW_SIZE)+aggressorPieceType]
 }
 
-func updateHistory(board *Board, move Move, bonus int16) bool {
-	if isQuietMove(move) {
-		side2move := board.GetTopState().TurnColor
-		piece := board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
-		to := getTargetPosition(move)
-		clampedBonus := max(min(bonus, HISTORY_MAX_HISTORY), -HISTORY_MAX_HISTORY)
+// Bad captures are ordered with a negative priority by mvv_lva_score
+func isBadCapture(move Move) bool {
+	return !isQuietMove(move) && move.priority < 0
+}
 
-		history[side2move][piece][to] +=
-			clampedBonus -
-				(history[side2move][piece][to] * max(-clampedBonus, clampedBonus) / HISTORY_MAX_HISTORY)
-		return true
+// Looks up the history tables of the current node, see historyContext
+func (thread *searchThread) historyContext() historyContext {
+	board := thread.board
+	history := historyContext{side: board.GetTopState().TurnColor}
+	if piece, to, ok := board.playedMove(1); ok {
+		history.counterMove = &thread.counterMoves[history.side^1][piece][to]
+		history.counterHistory = &thread.counterMoveHistory[history.side][piece][to]
 	}
-	return false
+	if piece, to, ok := board.playedMove(2); ok {
+		history.followUpHistory = &thread.followUpHistory[history.side][piece][to]
+	}
+	return history
 }
 
-func getHistory(board *Board, move Move) int16 {
-	side2move := board.GetTopState().TurnColor
-	piece := board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
+// History gravity, the bonus shrinks as the entry approaches HISTORY_MAX_HISTORY, so entries saturate instead of overflowing
+func updateHistoryEntry(entry *int16, bonus int16) {
+	clampedBonus := int32(max(min(bonus, HISTORY_MAX_HISTORY), -HISTORY_MAX_HISTORY))
+	*entry += int16(clampedBonus - int32(*entry)*max(-clampedBonus, clampedBonus)/int32(HISTORY_MAX_HISTORY))
+}
+
+// Updates the butterfly and continuation histories of a quiet move, returns false for any other move
+func (thread *searchThread) updateHistory(history *historyContext, move Move, bonus int16) bool {
+	if !isQuietMove(move) {
+		return false
+	}
+	piece := thread.board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
+	to := getTargetPosition(move)
+
+	updateHistoryEntry(&thread.history[history.side][piece][to], bonus)
+	if history.counterHistory != nil {
+		updateHistoryEntry(&history.counterHistory[piece][to], bonus)
+	}
+	if history.followUpHistory != nil {
+		updateHistoryEntry(&history.followUpHistory[piece][to], bonus)
+	}
+	return true
+}
+
+// Score of a quiet move for ordering and reductions, the average of its butterfly and continuation histories that exist at this node
+func (thread *searchThread) getQuietHistory(history *historyContext, move Move) int16 {
+	piece := thread.board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
 	to := getTargetPosition(move)
-	return history[side2move][piece][to]
+
+	score, tables := int(thread.history[history.side][piece][to]), 1
+	if history.counterHistory != nil {
+		score += int(history.counterHistory[piece][to])
+		tables++
+	}
+	if history.followUpHistory != nil {
+		score += int(history.followUpHistory[piece][to])
+		tables++
+	}
+	return int16(score / tables)
+}
+
+// Returns the type of the piece move captures, capture promotions are left out as they are ordered by their promotion
+func (board *Board) capturedPieceType(move Move) (int, bool) {
+	switch GetFlag(move) {
+	case captureFlag:
+		return board.PieceInfoArr[getTargetPosition(move)].pieceTYPE, true
+	case epCaptureFlag:
+		return PAWN, true
+	default:
+		return NULL_PIECE, false
+	}
+}
+
+// Updates the capture history of a capture, returns false for any other move
+func (thread *searchThread) updateCaptureHistory(move Move, bonus int16) bool {
+	victim, ok := thread.board.capturedPieceType(move)
+	if !ok {
+		return false
+	}
+	side2move := thread.board.GetTopState().TurnColor
+	piece := thread.board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
+	updateHistoryEntry(&thread.captureHistory[side2move][piece][getTargetPosition(move)][victim], bonus)
+	return true
+}
+
+func (thread *searchThread) getCaptureHistory(move Move) int16 {
+	victim, ok := thread.board.capturedPieceType(move)
+	if !ok {
+		return 0
+	}
+	side2move := thread.board.GetTopState().TurnColor
+	piece := thread.board.PieceInfoArr[getStartingPosition(move)].pieceTYPE
+	return thread.captureHistory[side2move][piece][getTargetPosition(move)][victim]
+}
+
+/*
+Rewards the move that caused a beta cutoff at depth and punishes the moves tried before it,
+a quiet cutoff move also becomes the counter move to the opponent's previous move
+*/
+func (thread *searchThread) updateCutoffHistories(history *historyContext, cutoffMove Move, triedMoves []Move, depth int8) {
+	bonus := int16(depth) * int16(depth)
+	if thread.updateHistory(history, cutoffMove, bonus) {
+		if history.counterMove != nil {
+			*history.counterMove = cutoffMove
+		}
+		for _, move := range triedMoves {
+			thread.updateHistory(history, move, -bonus)
+		}
+	} else {
+		thread.updateCaptureHistory(cutoffMove, bonus)
+	}
+	for _, move := range triedMoves {
+		thread.updateCaptureHistory(move, -bonus)
+	}
 }
 
-func resetHistory() {
-	history = [2][6][64]int16{}
+func (thread *searchThread) resetHistory() {
+	thread.history = [2][6][64]int16{}
+	thread.captureHistory = [2][6][64][6]int16{}
+	thread.counterMoves = [2][6][64]Move{}
+	thread.counterMoveHistory = continuationHistory{}
+	thread.followUpHistory = continuationHistory{}
 }
 
-func ageHistory() {
-	for piece := PAWN; piece <= KING; piece++ {
-		for to := A1; to <= H8; to++ {
-			history[WHITE][piece][to] = (history[WHITE][piece][to] * HISTORY_AGE_MULTIPLIER) / HISTORY_MULTIPLIER
-			history[BLACK][piece][to] = (history[BLACK][piece][to] * HISTORY_AGE_MULTIPLIER) / HISTORY_MULTIPLIER
+func ageHistoryTable(table []int16) {
+	for i := range table {
+		table[i] = (table[i] * HISTORY_AGE_MULTIPLIER) / HISTORY_MULTIPLIER
+	}
+}
+
+// Fades every history table, so the scores of the latest search count the most
+func (thread *searchThread) ageHistory() {
+	for color := WHITE; color <= BLACK; color++ {
+		for piece := PAWN; piece <= KING; piece++ {
+			ageHistoryTable(thread.history[color][piece][:])
+			for to := A1; to <= H8; to++ {
+				ageHistoryTable(thread.captureHistory[color][piece][to][:])
+				for prevPiece := PAWN; prevPiece <= KING; prevPiece++ {
+					ageHistoryTable(thread.counterMoveHistory[color][piece][to][prevPiece][:])
+					ageHistoryTable(thread.followUpHistory[color][piece][to][prevPiece][:])
+				}
+			}
 		}
 	}
 }
 
-func resetKillers() {
-	killerMoves = [MAX_POSSIBLE_DEPTH][2]Move{}
-	killerMovesCounter = [MAX_POSSIBLE_DEPTH][64][64]uint16{}
+func (thread *searchThread) resetKillers() {
+	thread.killerMoves = [MAX_POSSIBLE_DEPTH][2]Move{}
+	thread.killerMovesCounter = [MAX_POSSIBLE_DEPTH][64][64]uint16{}
 }
 
-func updateKiller(depth int8, move Move) {
+func (thread *searchThread) updateKiller(depth int8, move Move) {
 	if !isQuietMove(move) {
 		return
 	}
 
-	firstMove := killerMoves[depth][0]
-	secondMove := killerMoves[depth][1]
+	firstMove := thread.killerMoves[depth][0]
+	secondMove := thread.killerMoves[depth][1]
 
 	if move.enc == firstMove.enc {
 		return
 	}
 
-	killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] = min(65535, killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)]+1)
-
-	if killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] == 65535 {
-		fmt.Println("Killer move counter overflow!")
+	if counter := &thread.killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)]; *counter < 65535 { // Saturates instead of wrapping around
+		*counter++
 	}
 
-	if killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] >
-		killerMovesCounter[depth][getStartingPosition(firstMove)][getTargetPosition(firstMove)] {
-		killerMoves[depth][1] = killerMoves[depth][0]
-		killerMoves[depth][0] = move
-	} else if killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] >
-		killerMovesCounter[depth][getStartingPosition(secondMove)][getTargetPosition(secondMove)] {
-		killerMoves[depth][1] = move
+	if thread.killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] >
+		thread.killerMovesCounter[depth][getStartingPosition(firstMove)][getTargetPosition(firstMove)] {
+		thread.killerMoves[depth][1] = thread.killerMoves[depth][0]
+		thread.killerMoves[depth][0] = move
+	} else if thread.killerMovesCounter[depth][getStartingPosition(move)][getTargetPosition(move)] >
+		thread.killerMovesCounter[depth][getStartingPosition(secondMove)][getTargetPosition(secondMove)] {
+		thread.killerMoves[depth][1] = move
 	}
 }
 
-func getKiller(depth int8, move Move) int16 {
+func (thread *searchThread) getKiller(depth int8, move Move) int16 {
 	switch move.enc {
-	case killerMoves[depth][0].enc:
+	case thread.killerMoves[depth][0].enc:
 		return KILLER_MOVE_SCORE + 2
-	case killerMoves[depth][1].enc:
+	case thread.killerMoves[depth][1].enc:
 		return KILLER_MOVE_SCORE + 1
 	default:
 		return 0
@@ -0,0 +1,31 @@
+package chessengine
+
+import (
+	"testing"
+)
+
+func Test_QuietHistoryAverage(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+
+	thread := &searchThread{board: InitStartBoard()}
+	move, _ := thread.board.TryMoveUCI("g1f3")
+	thread.history[WHITE][KNIGHT][getTargetPosition(move)] = 300
+
+	// Before any move is played there is no continuation history, the butterfly history is all there is
+	history := thread.historyContext()
+	if score := thread.getQuietHistory(&history, move); score != 300 {
+		t.Fatalf("Quiet history %d without continuation histories, wanted 300", score)
+	}
+
+	// After one move of each side both continuation histories are averaged in
+	for _, moveUCI := range []string{"e2e4", "e7e5"} {
+		played, _ := thread.board.TryMoveUCI(moveUCI)
+		thread.board.MakeMove(played)
+	}
+	history = thread.historyContext()
+	history.counterHistory[KNIGHT][getTargetPosition(move)] = 600
+	if score := thread.getQuietHistory(&history, move); score != 300 {
+		t.Fatalf("Quiet history %d of 300, 600 and 0, wanted 300", score)
+	}
+}
@@ -0,0 +1,182 @@
+package chessengine
+
+// Stages of the move picker, in the order their moves are returned
+const (
+	STAGE_HASH_MOVES = iota
+	STAGE_GENERATE_CAPTURES
+	STAGE_GOOD_CAPTURES
+	STAGE_REFUTATIONS
+	STAGE_GENERATE_QUIETS
+	STAGE_QUIETS
+	STAGE_BAD_CAPTURES
+	STAGE_DONE
+)
+
+/*
+Staged move generation, https://www.chessprogramming.org/Move_Generation#Staged_move_generation
+the main search pulls its moves one at a time, so a cutoff by an early move saves generating and ordering the rest:
+ 1. PV and TT move, checked for legality without generating anything
+ 2. captures that do not lose material, best MVV-LVA first
+ 3. killers and counter move
+ 4. quiet moves, sorted by history
+ 5. captures that lose material
+
+Every legal move is returned exactly once, except for the excluded move and, at a restricted root, the moves not allowed there
+*/
+type movePicker struct {
+	thread      *searchThread
+	plyFromRoot int8
+	stage       int
+
+	hashMoves   [2]Move // PV move of the previous iteration and TT move
+	refutations [3]Move // Both killers and the counter move
+	early       [5]Move // Moves returned before generating, skipped once they are generated
+	earlyCount  int
+
+	moves           []Move // Captures followed by quiet moves, in the thread's move pool of this ply
+	captureEnd      int    // moves[:captureEnd] are the captures
+	badCaptureStart int    // Captures from here on lose material
+	index           int    // Next hash move or refutation, or next generated move
+
+	excludedMove Move
+	restrictRoot bool
+	history      historyContext
+}
+
+func (thread *searchThread) newMovePicker(plyFromRoot int8, pvMove, ttMove, excludedMove Move, restrictRoot bool) movePicker {
+	picker := movePicker{
+		thread:       thread,
+		plyFromRoot:  plyFromRoot,
+		hashMoves:    [2]Move{pvMove, ttMove},
+		excludedMove: excludedMove,
+		restrictRoot: restrictRoot,
+		history:      thread.historyContext(),
+	}
+	picker.refutations = [3]Move{thread.killerMoves[plyFromRoot][0], thread.killerMoves[plyFromRoot][1], NULL_MOVE}
+	if picker.history.counterMove != nil {
+		picker.refutations[2] = *picker.history.counterMove
+	}
+	return picker
+}
+
+// Returns the next move to search, NULL_MOVE once every move has been returned
+func (picker *movePicker) next() Move {
+	board := picker.thread.board
+	for {
+		switch picker.stage {
+		case STAGE_HASH_MOVES:
+			for picker.index < len(picker.hashMoves) {
+				move := picker.hashMoves[picker.index]
+				picker.index++
+				if picker.tryEarly(move) {
+					move.priority = [2]int16{PV_MOVE_SCORE, TT_MOVE_SCORE}[picker.index-1]
+					return move
+				}
+			}
+			picker.stage++
+
+		case STAGE_GENERATE_CAPTURES:
+			picker.moves = board.GenerateMoves(CAPTURE, picker.thread.searchMovePool[picker.plyFromRoot][:0])
+			for i := range picker.moves {
+				picker.moves[i].priority = picker.thread.scoreMove(picker.moves[i], picker.plyFromRoot, &picker.history)
+			}
+			picker.captureEnd = len(picker.moves)
+			picker.index = 0
+			picker.stage++
+
+		case STAGE_GOOD_CAPTURES:
+			if picker.index < picker.captureEnd {
+				move := picker.selectBest(picker.captureEnd)
+				if !isBadCapture(move) { // The best one left losing material means all of them do
+					picker.index++
+					if picker.allowed(move) {
+						return move
+					}
+					continue
+				}
+			}
+			picker.badCaptureStart = picker.index
+			picker.index = 0
+			picker.stage++
+
+		case STAGE_REFUTATIONS:
+			for picker.index < len(picker.refutations) {
+				move := picker.refutations[picker.index]
+				picker.index++
+				if isQuietMove(move) && picker.tryEarly(move) {
+					move.priority = [3]int16{KILLER_MOVE_SCORE + 2, KILLER_MOVE_SCORE + 1, COUNTER_MOVE_SCORE}[picker.index-1]
+					return move
+				}
+			}
+			picker.stage++
+
+		case STAGE_GENERATE_QUIETS:
+			picker.moves = board.GenerateMoves(QUIET, picker.moves)
+			quiets := picker.moves[picker.captureEnd:]
+			for i := range quiets {
+				quiets[i].priority = picker.thread.scoreMove(quiets[i], picker.plyFromRoot, &picker.history)
+			}
+			sortMoves(quiets)
+			picker.index = picker.captureEnd
+			picker.stage++
+
+		case STAGE_QUIETS:
+			if picker.index < len(picker.moves) {
+				move := picker.moves[picker.index]
+				picker.index++
+				if picker.allowed(move) {
+					return move
+				}
+				continue
+			}
+			picker.index = picker.badCaptureStart
+			picker.stage++
+
+		case STAGE_BAD_CAPTURES:
+			if picker.index < picker.captureEnd {
+				move := picker.selectBest(picker.captureEnd)
+				picker.index++
+				if picker.allowed(move) {
+					return move
+				}
+				continue
+			}
+			picker.stage++
+
+		default:
+			return NULL_MOVE
+		}
+	}
+}
+
+// Moves the best scored of moves[index:end] to index and returns it, a selection sort step
+func (picker *movePicker) selectBest(end int) Move {
+	best := picker.index
+	for i := picker.index + 1; i < end; i++ {
+		if picker.moves[i].priority > picker.moves[best].priority {
+			best = i
+		}
+	}
+	picker.moves[picker.index], picker.moves[best] = picker.moves[best], picker.moves[picker.index]
+	return picker.moves[picker.index]
+}
+
+// Accepts a move to be returned before any generation, it still has to be legal and must not be returned twice
+func (picker *movePicker) tryEarly(move Move) bool {
+	if move == NULL_MOVE || containsMove(picker.early[:picker.earlyCount], move) ||
+		!picker.allowedAtNode(move) || !picker.thread.board.isLegalMove(move) {
+		return false
+	}
+	picker.early[picker.earlyCount] = move
+	picker.earlyCount++
+	return true
+}
+
+// Whether a generated move is to be returned, it may have already been returned early
+func (picker *movePicker) allowed(move Move) bool {
+	return !containsMove(picker.early[:picker.earlyCount], move) && picker.allowedAtNode(move)
+}
+
+func (picker *movePicker) allowedAtNode(move Move) bool {
+	return move.enc != picker.excludedMove.enc && (!picker.restrictRoot || picker.thread.rootMoveAllowed(move))
+}
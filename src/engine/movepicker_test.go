@@ -0,0 +1,148 @@
+package chessengine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+/*
+Walks the perft tree pulling every node's moves from a move picker and checks it returns exactly the generated moves.
+The PV move, TT move, killers and counter move are taken from elsewhere in the tree, so they are often illegal,
+which also checks isLegalMove against the generated moves
+*/
+func pickerPerft(t *testing.T, thread *searchThread, plyFromRoot int8, depth int, seenMoves *[]Move, rng *rand.Rand) (nodes uint64) {
+	board := thread.board
+	legal := board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT))
+
+	randomMove := func() Move {
+		if len(*seenMoves) == 0 || rng.Intn(4) == 0 {
+			if len(legal) == 0 {
+				return NULL_MOVE
+			}
+			return legal[rng.Intn(len(legal))]
+		}
+		return (*seenMoves)[rng.Intn(len(*seenMoves))]
+	}
+	for i := 0; i < 8; i++ {
+		move := randomMove()
+		if board.isLegalMove(move) != containsMove(legal, move) {
+			t.Fatalf("isLegalMove(%s) = %v in\n%s", MoveToString(move), board.isLegalMove(move), board.DisplayBoard())
+		}
+	}
+
+	thread.killerMoves[plyFromRoot] = [2]Move{randomMove(), randomMove()}
+	if piece, to, ok := board.playedMove(1); ok {
+		thread.counterMoves[board.GetTopState().TurnColor^1][piece][to] = randomMove()
+	}
+	picker := thread.newMovePicker(plyFromRoot, randomMove(), randomMove(), NULL_MOVE, false)
+
+	returned := make([]Move, 0, len(legal))
+	for move := picker.next(); move != NULL_MOVE; move = picker.next() {
+		if !containsMove(legal, move) {
+			t.Fatalf("Picker returned illegal move %s in\n%s", MoveToString(move), board.DisplayBoard())
+		}
+		if containsMove(returned, move) {
+			t.Fatalf("Picker returned %s twice in\n%s", MoveToString(move), board.DisplayBoard())
+		}
+		returned = append(returned, move)
+
+		if len(*seenMoves) < 4096 {
+			*seenMoves = append(*seenMoves, move)
+		} else {
+			(*seenMoves)[rng.Intn(len(*seenMoves))] = move
+		}
+
+		if depth == 1 {
+			nodes++
+			continue
+		}
+		board.MakeMove(move)
+		nodes += pickerPerft(t, thread, plyFromRoot+1, depth-1, seenMoves, rng)
+		board.UnMakeMove()
+	}
+	if len(returned) != len(legal) {
+		t.Fatalf("Picker returned %d of the %d legal moves in\n%s", len(returned), len(legal), board.DisplayBoard())
+	}
+	return nodes
+}
+
+func Test_MovePickerPerft(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+
+	tests := []struct {
+		FEN      string
+		depth    int
+		expected uint64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 4, 197281},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, 43238},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, 9467},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		thread := &searchThread{board: InitFENBoard(test.FEN)}
+		seenMoves := make([]Move, 0, 4096)
+		if nodes := pickerPerft(t, thread, 0, test.depth, &seenMoves, rng); nodes != test.expected {
+			t.Fatalf("Picker perft(%d) of %s\n\texpected: %d\n\tgot: %d", test.depth, test.FEN, test.expected, nodes)
+		}
+	}
+}
+
+func Test_MovePickerExcludedMove(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+
+	thread := &searchThread{board: InitStartBoard()}
+	excluded, _ := thread.board.TryMoveUCI("e2e4")
+	// The excluded move is not returned even as the TT move
+	picker := thread.newMovePicker(0, NULL_MOVE, excluded, excluded, false)
+	count := 0
+	for move := picker.next(); move != NULL_MOVE; move = picker.next() {
+		if move.enc == excluded.enc {
+			t.Fatalf("Picker returned the excluded move")
+		}
+		count++
+	}
+	if count != 19 {
+		t.Fatalf("Picker returned %d moves without the excluded one, wanted 19", count)
+	}
+}
+
+func Test_MovePickerKillerFromSearch(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	board := InitFENBoard("r1bqkb1r/4npp1/p1p4p/1p1pP1B1/8/1B6/PPPN1PPP/R2Q1RK1 w kq - 0 1")
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	searchEngine.Search(board, SearchLimits{Depth: 6, Deterministic: true})
+	thread := searchEngine.threads[0]
+	killer := thread.killerMoves[1][0]
+	if killer == NULL_MOVE || !isQuietMove(killer) {
+		t.Fatalf("Search stored no quiet killer at ply 1, got %s", MoveToString(killer))
+	}
+
+	// The killer refuted some root move, wherever it is legal after one it comes right after the good captures
+	found := false
+	for _, rootMove := range board.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT)) {
+		board.MakeMove(rootMove)
+		if board.isLegalMove(killer) {
+			found = true
+			picker := thread.newMovePicker(1, NULL_MOVE, NULL_MOVE, NULL_MOVE, false)
+			move := picker.next()
+			for ; move != NULL_MOVE && !isQuietMove(move); move = picker.next() {
+			}
+			if move.enc != killer.enc || move.priority != KILLER_MOVE_SCORE+2 {
+				t.Fatalf("After %s the first quiet move picked was %s (%d), wanted the killer %s",
+					MoveToString(rootMove), MoveToString(move), move.priority, MoveToString(killer))
+			}
+		}
+		board.UnMakeMove()
+	}
+	if !found {
+		t.Fatalf("Killer %s is not legal after any root move", MoveToString(killer))
+	}
+}
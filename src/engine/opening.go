@@ -3,6 +3,7 @@ package chessengine
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
@@ -97,23 +98,36 @@ func CreateOpeningBook(inputFilePath, outputFilePath string, maxDepth int) {
 	writeMapToFile()
 }
 
-func (board *Board) GetOpeningBookMove() Move {
+// Returns a book move for the current position, picked at random unless deterministic,
+// in which case the first listed move is always played
+func (board *Board) GetOpeningBookMove(deterministic bool) Move {
 	if !board.GetTopState().useOpeningBook {
 		return NULL_MOVE
 	}
 
-	zobristKey := board.GetTopState().ZobristKey
-
 	file, err := os.Open(openingBookFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer file.Close()
-	scanner := bufio.NewScanner(file)
+	if move, ok := readOpeningBookMove(file, board.GetTopState().ZobristKey, deterministic); ok {
+		return move
+	}
+	// Could not find opening book move, switch to search
+	board.GetTopState().useOpeningBook = false
+	return NULL_MOVE
+}
+
+/*
+Looks zobristKey up in a book of lines "<key> <move> <move> ...", as written by CreateOpeningBook.
+Every line of the book ends with a space, so the moves are split on runs of whitespace and never include an empty one
+*/
+func readOpeningBookMove(book io.Reader, zobristKey uint64, deterministic bool) (Move, bool) {
+	scanner := bufio.NewScanner(book)
 	for scanner.Scan() {
 		line := scanner.Text()
 		// Split the line into a key and a list of moves
-		parts := strings.Split(line, " ")
+		parts := strings.Fields(line)
 		var key uint64
 		_, err := fmt.Sscanf(parts[0], "%d", &key)
 		if err != nil {
@@ -123,11 +137,13 @@ func (board *Board) GetOpeningBookMove() Move {
 			// Get the list of moves
 			moves := parts[1:]
 			// Pick a random move from the list
-			move, _ := strconv.Atoi(moves[rand.Intn(len(moves))])
-			return Move{enc: uint16(move)}
+			pick := 0
+			if !deterministic {
+				pick = rand.Intn(len(moves))
+			}
+			move, _ := strconv.Atoi(moves[pick])
+			return Move{enc: uint16(move)}, true
 		}
 	}
-	// Could not find opening book move, switch to search
-	board.GetTopState().useOpeningBook = false
-	return NULL_MOVE
+	return NULL_MOVE, false
 }
@@ -0,0 +1,28 @@
+package chessengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_OpeningBookParsing(t *testing.T) {
+	// Lines as CreateOpeningBook writes them, every one ends with a space
+	book := "415757109048512 2942 \n2755903260837918 18154 2237 \n"
+
+	for i := 0; i < 100; i++ {
+		move, ok := readOpeningBookMove(strings.NewReader(book), 415757109048512, false)
+		if !ok || move.enc != 2942 {
+			t.Fatalf("Book move %d found: %v, wanted the only move 2942", move.enc, ok)
+		}
+		move, ok = readOpeningBookMove(strings.NewReader(book), 2755903260837918, false)
+		if !ok || (move.enc != 18154 && move.enc != 2237) {
+			t.Fatalf("Book move %d found: %v, wanted 18154 or 2237", move.enc, ok)
+		}
+	}
+	if move, ok := readOpeningBookMove(strings.NewReader(book), 2755903260837918, true); !ok || move.enc != 18154 {
+		t.Fatalf("Deterministic book move %d found: %v, wanted the first move 18154", move.enc, ok)
+	}
+	if move, ok := readOpeningBookMove(strings.NewReader(book), 12345, false); ok {
+		t.Fatalf("Book move %d found for a position not in the book", move.enc)
+	}
+}
@@ -1,6 +1,13 @@
 package chessengine
 
+// Move pool for perft, allocated per call so several boards can be counted at once
+type perftMovePool [MAX_POSSIBLE_DEPTH][MAX_MOVE_COUNT]Move
+
 func Perft(board *Board, ply int, rootLevel bool) (retval uint64, rootNodes map[string]uint64) {
+	return perft(board, ply, rootLevel, new(perftMovePool))
+}
+
+func perft(board *Board, ply int, rootLevel bool, movePool *perftMovePool) (retval uint64, rootNodes map[string]uint64) {
 	if ply == 0 {
 		return 1, nil
 	}
@@ -9,14 +16,14 @@ func Perft(board *Board, ply int, rootLevel bool) (retval uint64, rootNodes map[
 	}
 
 	// Reset this entry in the moveList pool back to having 0 entries
-	moveList := board.GenerateMoves(ALL, searchMovePool[ply][:0])
+	moveList := board.GenerateMoves(ALL, movePool[ply][:0])
 	if ply == 1 && !rootLevel {
 		return uint64(len(moveList)), nil
 	}
 
 	for _, move := range moveList {
 		board.MakeMove(move)
-		leafCount, _ := Perft(board, ply-1, false)
+		leafCount, _ := perft(board, ply-1, false, movePool)
 		retval += leafCount
 		if rootLevel {
 			rootNodes[MoveToString(move)] += leafCount
@@ -2,6 +2,9 @@ package chessengine
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,28 +19,83 @@ const (
 	MAX_QSEARCH_DEPTH           = 30
 	MAX_SEARCH_DEPTH            = 63
 	MAX_POSSIBLE_DEPTH          = MAX_EXTENSION_DEPTH + MAX_SEARCH_DEPTH
-	NULL_MOVE_REDUCTION    int8 = 2
+	NULL_MOVE_REDUCTION    int8 = 2 // Base reduction of the null move search, grows with depth and with the static eval's margin over beta
+	NULL_MOVE_VERIFY_DEPTH int8 = 8 // From this depth on a null move cutoff is verified by a reduced search without null moves
 	DELTAPRUNE_MARGIN           = 200
+	ASPIRATION_WINDOW           = 25  // Half width of the first aspiration window, doubled on every fail
+	ASPIRATION_MAX_WINDOW       = 400 // Windows wider than this are given up for a full window search
+	ASPIRATION_MIN_DEPTH        = 4   // Scores of shallower iterations swing too much to centre a window on
 	LATE_GAME_PHASE_CUTOFF      = 4
+	STOP_CHECK_INTERVAL         = 1024 // Nodes a thread searches between two looks at the stop flag, the main thread checks the node and time limits along with it
 	triangleTableSize           = ((MAX_SEARCH_DEPTH+MAX_EXTENSION_DEPTH)*(MAX_SEARCH_DEPTH+MAX_EXTENSION_DEPTH) + (MAX_POSSIBLE_DEPTH)) / 2
 	squareTableSize             = (MAX_POSSIBLE_DEPTH) * (MAX_POSSIBLE_DEPTH)
 )
 
+// Deepest nodes the static eval based pruning is tried at, its margins are set with Engine.SetPruningMargins
+const (
+	REVERSE_FUTILITY_MAX_DEPTH int8 = 3
+	FUTILITY_MAX_DEPTH         int8 = 3
+	RAZORING_MAX_DEPTH         int8 = 3
+	LATE_MOVE_PRUNING_DEPTH    int8 = 3 // Deepest nodes whose late quiet moves are pruned
+)
+
+// Late move reductions, https://www.chessprogramming.org/Late_Move_Reductions
+const (
+	LMR_MIN_DEPTH           int8 = 3
+	LMR_BASE                     = 0.75
+	LMR_DIVISOR                  = 2.25
+	LMR_HISTORY_DIVISOR          = HISTORY_MAX_HISTORY / 2 // A quiet move reduces one ply less or more per this much history
+	LMR_MAX_REDUCTION_STATS      = 7                       // Reductions of this or more share one bucket of the debug statistics
+)
+
+// Singular extensions, https://www.chessprogramming.org/Singular_Extensions
+const (
+	SINGULAR_MIN_DEPTH      int8 = 6 // Shallowest nodes the TT move is tested for singularity at
+	SINGULAR_TT_DEPTH_SLACK int8 = 3 // How much shallower than the node the TT entry may be and still be trusted
+	SINGULAR_MARGIN              = 2 // Margin in cp per ply of depth the other moves have to stay below the TT score
+)
+
+// Nodes without a TT move, see InternalIterativeMode
+const (
+	IIR_MIN_DEPTH int8 = 6 // Shallowest nodes reduced by internal iterative reductions
+)
+
+// Base reduction by depth left and move number, the later the move and the deeper the node, the less likely the move is best
+var lmrTable [MAX_POSSIBLE_DEPTH + 1][MAX_MOVE_COUNT]int8
+
+func init() {
+	for depth := 1; depth <= MAX_POSSIBLE_DEPTH; depth++ {
+		for moveIndex := 1; moveIndex < MAX_MOVE_COUNT; moveIndex++ {
+			lmrTable[depth][moveIndex] = int8(LMR_BASE + math.Log(float64(depth))*math.Log(float64(moveIndex))/LMR_DIVISOR)
+		}
+	}
+}
+
+// Number of moves searched at a node before late move pruning skips the remaining quiet moves
+func lateMovePruningCount(depth int8, improving bool) int {
+	count := 3 + int(depth)*int(depth)
+	if !improving {
+		count /= 2
+	}
+	return count
+}
+
 type searchInfo struct {
 	startTime time.Time
 	depth     int8
-	score     int
 	seldepth  int8
-	multipv   byte
 	leafNodes uint64
 	debug     debugInfo
 }
 
-var prevIterationNodeCount uint64 // for branching factor calculation
-
 type debugInfo struct {
 	qNodes           uint64
 	qNodeDeltaPrunes uint64
+	qNodeSEEPrunes   uint64
+
+	reverseFutilityCuts uint64
+	razorCuts           uint64
+	futilityPrunes      uint64
 
 	pvNodes  uint64
 	allNodes uint64
@@ -49,6 +107,13 @@ type debugInfo struct {
 
 	reducedNodes  uint64
 	amountReduced uint64
+	reductions    [LMR_MAX_REDUCTION_STATS + 1]uint64 // Number of reduced moves by reduction
+	lmpPrunes     uint64
+
+	singularExtensions uint64
+	multiCuts          uint64
+
+	iirReductions uint64
 
 	siblingNodes uint64
 
@@ -64,69 +129,269 @@ type debugInfo struct {
 	probeCUTNodesCorrect uint64
 }
 
-var latestSearchInfo searchInfo
+/*
+searchThread holds all of the state a single search goroutine writes to,
+every thread searches its own copy of the board and only shares the transposition table
+*/
+type searchThread struct {
+	id     int
+	engine *Engine
+	board  *Board
+	nodes  atomic.Uint64 // Nodes searched over the whole search, read by the main thread for reporting and the node limit
 
-var bestEvalThisIteration int
-var currentSearchTurn byte
+	// The search is unwound as soon as a thread sees it stopped, every node returns without touching the TT or the PV.
+	// The shared flag is only read every STOP_CHECK_INTERVAL nodes, so a select per node does not cost NPS
+	stopped         bool
+	nodesUntilCheck int
 
-var savedPV [MAX_POSSIBLE_DEPTH]Move // Principal variation lists
+	latestSearchInfo       searchInfo
+	prevIterationNodeCount uint64 // for branching factor calculation
 
-var searchMovePool [MAX_POSSIBLE_DEPTH][MAX_MOVE_COUNT]Move    // Move pool for main-search
-var qsearchMovePool [MAX_QSEARCH_DEPTH][MAX_CAPTURE_COUNT]Move // Move pool for quiescence search
+	bestEvalThisIteration int // Score of the root move in the PV table, the result of an iteration that is stopped before it is done
+	currentSearchTurn     byte
 
-var pv [squareTableSize]Move
-var pvPtr int
+	savedPV [MAX_POSSIBLE_DEPTH]Move // Principal variation lists
 
-func (board *Board) StartSearchNoDepth(startTime time.Time, cancelChannel chan struct{}) Move {
-	return board.initSearch(startTime, MAX_SEARCH_DEPTH, cancelChannel)
-}
+	multiPVLines      []PVLine // Lines of the latest iteration ordered by score, multiPVLines[0] matches savedPV
+	searchedRootMoves []Move   // Root moves that already have a line this iteration, skipped by the root search
+	searchMoves       []Move   // Root moves the search is restricted to, nil = all legal moves
+
+	searchMovePool  [MAX_POSSIBLE_DEPTH][MAX_MOVE_COUNT]Move // Move pool for main-search
+	triedMovePool   [MAX_POSSIBLE_DEPTH][MAX_MOVE_COUNT]Move // Moves each node of the main search has searched so far
+	qsearchMovePool [MAX_QSEARCH_DEPTH][MAX_MOVE_COUNT]Move  // Move pool for quiescence search, evasions and quiet checks need more room than captures
+
+	pv    [squareTableSize]Move
+	pvPtr int
+
+	staticEvals   [MAX_POSSIBLE_DEPTH]int  // Static eval of each node on the current line, MIN_VALUE when in check
+	excludedMoves [MAX_POSSIBLE_DEPTH]Move // Move skipped by the node at each ply while its TT move is tested for singularity
+
+	// History heuristic variables
+	history            [2][6][64]int16
+	captureHistory     [2][6][64][6]int16 // side to move, piece, target and captured piece type
+	counterMoves       [2][6][64]Move     // color, piece and target of the move refuted
+	counterMoveHistory continuationHistory
+	followUpHistory    continuationHistory
 
-func (board *Board) StartSearchDepth(startTime time.Time, max_depth int8, cancelChannel chan struct{}) (score Move, nodes uint64, timeTaken int64) {
-	return board.initSearch(startTime, max_depth, cancelChannel), latestSearchInfo.leafNodes, time.Since(startTime).Milliseconds()
+	// Killer heuristic variables
+	killerMoves        [MAX_POSSIBLE_DEPTH][2]Move
+	killerMovesCounter [MAX_POSSIBLE_DEPTH][64][64]uint16
 }
 
-func (board *Board) initSearch(startTime time.Time, max_depth int8, cancelChannel chan struct{}) Move {
+func (thread *searchThread) iterativeDeepening(startTime time.Time, max_depth int8) Move {
 	var depth int8 = 1
-	pvPtr = 0
-	pv = [squareTableSize]Move{}
-	savedPV = [MAX_POSSIBLE_DEPTH]Move{}
-	resetHistory()
-	resetKillers()
-	if DebugMode {
-		TTDebugReset(board)
+	if thread.id%2 == 1 {
+		depth = 2 // Odd helpers skip a ply ahead of the main thread to desynchronise the search trees
+	}
+	board := thread.board
+	thread.pvPtr = 0
+	thread.pv = [squareTableSize]Move{}
+	thread.savedPV = [MAX_POSSIBLE_DEPTH]Move{}
+	thread.multiPVLines = nil
+	thread.resetKillers()
+	thread.ageHistory() // The histories carry over from the previous search, aged once so this one's scores count the most
+
+	thread.currentSearchTurn = board.GetTopState().TurnCounter
+	thread.bestEvalThisIteration = MIN_VALUE
+	thread.stopped = false
+	thread.nodesUntilCheck = 0 // The limits are checked on the first node, a search may be stopped before it starts
+
+	// Helpers only feed the transposition table, so only the main thread searches more than one line
+	multiPV := 1
+	if thread.id == 0 {
+		multiPV = min(thread.engine.multiPV, len(thread.filterRootMoves(board.GenerateMoves(ALL, thread.searchMovePool[0][:0]))))
+	}
+	// A mate search only needs the depths at which the side to move can deliver mate
+	var depthStep int8 = 1
+	if thread.engine.mateSearch {
+		depthStep = 2
 	}
-
-	currentSearchTurn = board.GetTopState().TurnCounter
-	bestEvalThisIteration = MIN_VALUE
 
 	for depth <= max_depth {
 
-		latestSearchInfo = searchInfo{startTime: startTime, multipv: 1, depth: depth}
-		ageHistory()
-		pv = [squareTableSize]Move{}
+		thread.latestSearchInfo = searchInfo{startTime: startTime, depth: depth}
 		// killerMovesCounter = [MAX_POSSIBLE_DEPTH][64][64]uint16{}
 
-		board.search(depth, 0, MIN_VALUE, MAX_VALUE, 0, false, true, cancelChannel)
+		// Every line searches the root without the first moves of the lines found before it
+		lines := make([]PVLine, 0, multiPV)
+		thread.searchedRootMoves = thread.searchedRootMoves[:0]
+		for len(lines) < multiPV {
+			thread.pv = [squareTableSize]Move{}
+			var score int
+			bound := ExactBound
+			if thread.engine.mateSearch {
+				score = thread.mateSearch(depth, 0, MIN_VALUE, MAX_VALUE)
+				if thread.stopped {
+					score = thread.bestEvalThisIteration
+				}
+			} else {
+				score, bound = thread.aspirationSearch(depth, len(lines))
+			}
+			// A stopped line is still the best of the root moves it searched in full, and the first of those is the previous best move.
+			// Only a failed low aspiration search has nothing to show for it, the previous iteration's line stands then
+			if thread.pv[0] == NULL_MOVE || (thread.stopped && bound == UpperBound) {
+				break
+			}
+			lines = append(lines, thread.newPVLine(score, bound, depth))
+			if thread.stopped {
+				break
+			}
+			thread.searchedRootMoves = append(thread.searchedRootMoves, thread.pv[0])
+		}
+		thread.searchedRootMoves = thread.searchedRootMoves[:0]
+
+		if len(lines) > 0 {
+			sort.SliceStable(lines, func(i, j int) bool { return lines[i].Score > lines[j].Score })
+			// Lines that were not reached before the search was stopped keep their result from the previous iteration
+			for _, prevLine := range thread.multiPVLines {
+				if len(lines) == multiPV {
+					break
+				}
+				if !containsRootMove(lines, prevLine.PV[0]) {
+					lines = append(lines, prevLine)
+				}
+			}
+			thread.multiPVLines = lines
 
-		if pv[0] != NULL_MOVE {
-			copy(savedPV[:depth], pv[:depth])
-			depth++
-			latestSearchInfo.score = bestEvalThisIteration
+			thread.savedPV = [MAX_POSSIBLE_DEPTH]Move{}
+			copy(thread.savedPV[:], lines[0].PV)
+			depth += depthStep
 
-			fmt.Println(engineInfoString())
-			if DebugMode {
-				fmt.Print(TTDebugInfo())
+			if thread.id == 0 {
+				thread.reportLines(DebugMode)
+			}
+
+			// The shortest forced mate has been proven, deeper iterations can only find longer ones
+			if thread.engine.mateSearch && scoreIsCheckmate(lines[0].Score) > 0 {
+				return thread.savedPV[0]
+			}
+			// The soft time limit is only checked between iterations, the hard limit is polled by the search itself
+			if thread.id == 0 && thread.engine.timeManager != nil && !thread.engine.stopped.Load() &&
+				thread.engine.timeManager.IterationDone(MoveToString(lines[0].PV[0]), lines[0].Score) {
+				return thread.savedPV[0]
 			}
 		}
 
-		select {
-		case <-cancelChannel:
-			return savedPV[0]
-		default:
+		if thread.stopped || thread.engine.stopped.Load() {
+			// The last line reported may be a bound of the unfinished iteration, the lines the move is played from are reported last
+			if len(lines) == 0 && thread.id == 0 {
+				thread.reportLines(false)
+			}
+			return thread.savedPV[0]
 		}
 	}
 
-	return savedPV[0]
+	return thread.savedPV[0]
+}
+
+// Sends the main thread's lines to the observer, along with the statistics of the iteration when withDebugInfo is set
+func (thread *searchThread) reportLines(withDebugInfo bool) {
+	if thread.engine.observer == nil {
+		return
+	}
+	for i, line := range thread.multiPVLines {
+		info := thread.iterationInfo(i+1, line)
+		if withDebugInfo && i == 0 {
+			info.DebugInfo = thread.debugInfoString() + thread.engine.tt.TTDebugInfo()
+		}
+		thread.engine.observer.Iteration(info)
+	}
+}
+
+func (thread *searchThread) iterationInfo(multiPV int, line PVLine) IterationInfo {
+	return IterationInfo{
+		Line:     line,
+		MultiPV:  multiPV,
+		Nodes:    thread.engine.Nodes(),
+		Time:     time.Since(thread.latestSearchInfo.startTime),
+		HashFull: thread.engine.tt.hashFull(),
+	}
+}
+
+func (thread *searchThread) newPVLine(score int, bound ScoreBound, depth int8) PVLine {
+	return PVLine{Score: score, Bound: bound, Depth: depth, SelDepth: depth + thread.latestSearchInfo.seldepth, PV: thread.pvLine(depth)}
+}
+
+// Tells the observer about a root move the main thread is about to search
+func (thread *searchThread) reportRootMove(depth int8, move Move, moveNumber int) {
+	if thread.id == 0 && thread.engine.observer != nil {
+		thread.engine.observer.RootMove(RootMoveInfo{Depth: depth, Move: move, MoveNumber: moveNumber, Time: time.Since(thread.latestSearchInfo.startTime)})
+	}
+}
+
+// Copies the root PV found by the latest search out of the PV table
+func (thread *searchThread) pvLine(depth int8) (line []Move) {
+	for i := int8(0); i < depth && thread.pv[i] != NULL_MOVE; i++ {
+		line = append(line, thread.pv[i])
+	}
+	return line
+}
+
+func containsRootMove(lines []PVLine, move Move) bool {
+	for _, line := range lines {
+		if line.PV[0].enc == move.enc {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeps the root moves of moveList that are among the searchmoves and do not have a MultiPV line yet this iteration
+func (thread *searchThread) filterRootMoves(moveList []Move) []Move {
+	remaining := moveList[:0]
+	for _, move := range moveList {
+		if thread.rootMoveAllowed(move) {
+			remaining = append(remaining, move)
+		}
+	}
+	return remaining
+}
+
+func (thread *searchThread) rootMoveAllowed(move Move) bool {
+	return (len(thread.searchMoves) == 0 || containsMove(thread.searchMoves, move)) && !containsMove(thread.searchedRootMoves, move)
+}
+
+func containsMove(moveList []Move, move Move) bool {
+	for _, listMove := range moveList {
+		if listMove.enc == move.enc {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts every node of the main search and quiescence search, and looks whether the search has been stopped every STOP_CHECK_INTERVAL nodes
+func (thread *searchThread) countNode() {
+	thread.nodes.Add(1)
+	thread.nodesUntilCheck--
+	if thread.nodesUntilCheck <= 0 {
+		thread.checkStop()
+	}
+}
+
+// The main thread stops all threads once the node limit or the hard time limit is reached
+func (thread *searchThread) checkStop() {
+	engine := thread.engine
+	thread.nodesUntilCheck = STOP_CHECK_INTERVAL
+	if thread.id == 0 {
+		if engine.nodeLimit != 0 {
+			nodes := engine.Nodes()
+			if nodes >= engine.nodeLimit {
+				engine.stopped.Store(true)
+			} else {
+				// Checked again right on the limit, which keeps a deterministic search exact to the node
+				thread.nodesUntilCheck = int(min(STOP_CHECK_INTERVAL, engine.nodeLimit-nodes))
+			}
+		}
+		if engine.timeManager != nil && engine.timeManager.HardLimitReached() {
+			engine.stopped.Store(true)
+		}
+	}
+	thread.stopped = engine.stopped.Load()
+}
+
+func (thread *searchThread) countLeafNode() {
+	thread.latestSearchInfo.leafNodes++
 }
 
 // search performs an alpha-beta pruning of minimax search on the chess board up to the specified depth.
@@ -136,261 +401,578 @@ func (board *Board) initSearch(startTime time.Time, max_depth int8, cancelChanne
 // The plyFromRoot parameter specifies the current ply (half-move) count from the root position.
 // The alpha and beta parameters define the current alpha-beta window.
 // The numExtensions parameter specifies the number of extensions to apply during the search.
-// The cancelChannel parameter is used to cancel the search if needed.
-// If the search is cancelled, the function returns 0.
-func (board *Board) search(depth, plyFromRoot int8, alpha, beta int, numExtensions int8, searchReduced, doNullMove bool, cancelChannel chan struct{}) int {
-	// Check if the search has been cancelled
-	select {
-	case <-cancelChannel:
-		return bestEvalThisIteration
-	default:
+// Once the search has been stopped, the function returns a meaningless score that every caller discards.
+func (thread *searchThread) search(depth, plyFromRoot int8, alpha, beta int, numExtensions int8, doNullMove bool) int {
+	board := thread.board
+	thread.pv[thread.pvPtr] = NULL_MOVE // Nodes that return early leave an empty PV behind, not a stale one
+
+	// Check if the search has been stopped
+	if thread.stopped {
+		return thread.bestEvalThisIteration
 	}
+	thread.countNode()
 
 	if plyFromRoot > 0 {
-		// Fifty move rule, Insufficient Material, Threefold repetition
+		// Fifty move rule, Insufficient Material, Repetition
 		if board.GetTopState().HalfMoveClock >= 100 ||
 			isInsufficientMaterial(board) ||
-			board.RepetitionPositionHistory[board.GetTopState().ZobristKey] == 3 {
-			latestSearchInfo.leafNodes++
+			board.isRepetition(plyFromRoot) {
+			thread.countLeafNode()
 			return DRAW_SCORE
 		}
+
+		// Upcoming repetition, https://www.chessprogramming.org/Repetitions#Cuckoo_Tables
+		// with a move back to an earlier position at hand, e.g. a perpetual check, the side to move scores at least a draw
+		if alpha < DRAW_SCORE && board.hasUpcomingRepetition(plyFromRoot) {
+			alpha = DRAW_SCORE
+			if alpha >= beta {
+				thread.countLeafNode()
+				return alpha
+			}
+		}
+
+		// Mate Distance Pruning, https://www.chessprogramming.org/Mate_Distance_Pruning
+		// being mated here is the worst and mating on the next ply the best this node can score,
+		// so nothing can be found here once a shorter mate is known closer to the root
+		alpha = max(alpha, MATE_SCORE+int(plyFromRoot))
+		beta = min(beta, -MATE_SCORE-int(plyFromRoot)-1)
+		if alpha >= beta {
+			return alpha
+		}
 	}
 
 	if depth <= 0 {
-		eval := board.quiescenceSearch(alpha, beta, 0, cancelChannel)
-		latestSearchInfo.leafNodes++
+		eval := thread.quiescenceSearch(alpha, beta, plyFromRoot, 0)
+		thread.countLeafNode()
 		return eval
 	}
 
-	probeScore, probeNodeType, probeMove := probeHash(depth, currentSearchTurn, alpha, beta, board.GetTopState().ZobristKey)
-	if probeScore != MIN_VALUE {
-		if plyFromRoot == 0 && probeNodeType == PVnode {
-			bestEvalThisIteration = probeScore
-		}
+	// A node searched without one of its moves neither uses nor stores TT scores, they belong to the position with all of its moves
+	excludedMove := thread.excludedMoves[plyFromRoot]
+
+	probeScore, probeNodeType, probeMove := thread.engine.tt.probeHash(depth, plyFromRoot, thread.currentSearchTurn, alpha, beta, board.GetTopState().ZobristKey)
+	// Never cut at the root, another thread may have already stored this iteration's result, which would leave this thread without a PV
+	if probeScore != MIN_VALUE && plyFromRoot > 0 && excludedMove == NULL_MOVE {
 		return probeScore
 	}
 
-	// lazyEval, _, egPhase := board.Evaluate()
 	inPVNode := alpha != beta-1
 
+	staticEval := MIN_VALUE
+	if !board.InCheck() {
+		staticEval, _, _ = board.Evaluate()
+	}
+	thread.staticEvals[plyFromRoot] = staticEval
+	// Whether the side to move is doing better than on its previous move, if not it is pruned and reduced harder
+	improving := plyFromRoot < 2 || staticEval > thread.staticEvals[plyFromRoot-2]
+
+	// The static eval says little in check and near mate, and PV nodes are always searched in full
+	canPrune := !inPVNode && plyFromRoot > 0 && !board.InCheck() && scoreIsCheckmate(alpha) == 0 && scoreIsCheckmate(beta) == 0
+	margins := &thread.engine.margins
+
+	// Reverse Futility Pruning, https://www.chessprogramming.org/Reverse_Futility_Pruning
+	// so far above beta that no reply is expected to bring the score back down within the depth left
+	if canPrune && depth <= REVERSE_FUTILITY_MAX_DEPTH && staticEval-margins.ReverseFutility*int(depth) >= beta {
+		thread.latestSearchInfo.debug.reverseFutilityCuts++
+		return staticEval
+	}
+
+	// Razoring, https://www.chessprogramming.org/Razoring
+	// so far below alpha that only a capture could save the node, which the quiescence search finds
+	if canPrune && depth <= RAZORING_MAX_DEPTH && staticEval+margins.Razoring*int(depth) < alpha {
+		if razorScore := thread.quiescenceSearch(alpha, beta, plyFromRoot, 0); razorScore <= alpha {
+			thread.latestSearchInfo.debug.razorCuts++
+			return razorScore
+		}
+	}
+
 	// Null Move Pruning, https://www.chessprogramming.org/Null_Move_Pruning
-	// if doNullMove && !inPVNode && depth > 1 && egPhase != 24 && !board.InCheck() && lazyEval >= beta-50 {
-	// 	board.MakeMove(NULL_MOVE)
-	// 	nullScore := -board.search(depth-1-NULL_MOVE_REDUCTION, plyFromRoot+1, -beta, -beta+1, numExtensions, searchReduced, false, cancelChannel)
-	// 	board.UnMakeMove()
-	// 	if nullScore >= beta {
-	// 		if nullScore >= -MATE_SCORE-(MAX_SEARCH_DEPTH+MAX_EXTENSION_DEPTH) {
-	// 			nullScore = beta
-	// 		}
-
-	// 		return nullScore
-	// 	}
-	// }
+	// Zugzwang makes passing the best move in pawn endgames, and passing is illegal in check
+	if doNullMove && canPrune && excludedMove == NULL_MOVE && depth >= 2 && staticEval >= beta && board.hasNonPawnMaterial() {
+		// Adaptive reduction, the deeper the search and the further the eval is above beta, the less a reply can change
+		reduction := NULL_MOVE_REDUCTION + depth/4 + int8(min((staticEval-beta)/200, 2))
+
+		board.MakeNullMove()
+		thread.pvPtr += int(MAX_POSSIBLE_DEPTH)
+		nullScore := -thread.search(depth-1-reduction, plyFromRoot+1, -beta, -beta+1, numExtensions, false)
+		thread.pvPtr -= int(MAX_POSSIBLE_DEPTH)
+		board.UnMakeNullMove()
+
+		if thread.stopped {
+			return thread.bestEvalThisIteration
+		}
+		if nullScore >= beta {
+			// A mate found after passing is not a mate the side to move can force, only claim beta
+			if scoreIsCheckmate(nullScore) != 0 {
+				nullScore = beta
+			}
+			if depth < NULL_MOVE_VERIFY_DEPTH {
+				return nullScore
+			}
+			// Deep cutoffs are verified without null moves, which catches the zugzwangs the guards above miss
+			if thread.search(depth-reduction, plyFromRoot, beta-1, beta, numExtensions, false) >= beta {
+				return nullScore
+			}
+		}
+	}
 
-	moveList := board.GenerateMoves(ALL, searchMovePool[plyFromRoot][:0])
-	board.moveordering(savedPV[plyFromRoot], probeMove, plyFromRoot, moveList)
+	// Without a TT move the first moves are only guessed, and a poor first move makes every other move searched after it costlier,
+	// so the node is not worth its full depth yet, the next iteration finds the move this one stores
+	if thread.engine.iiMode == InternalIterativeReductions && plyFromRoot > 0 && probeMove == NULL_MOVE && excludedMove == NULL_MOVE && depth >= IIR_MIN_DEPTH {
+		thread.latestSearchInfo.debug.iirReductions++
+		depth--
+	}
 
-	if len(moveList) == 0 {
-		latestSearchInfo.leafNodes++
+	// Singular Extensions, https://www.chessprogramming.org/Singular_Extensions
+	// the TT move is tested with a reduced search of all the other moves against a bound below its stored score,
+	// if they all fail low the TT move is singular and is extended
+	singularMove, singularExtension := NULL_MOVE, int8(0)
+	if plyFromRoot > 0 && depth >= SINGULAR_MIN_DEPTH && excludedMove == NULL_MOVE && numExtensions < MAX_EXTENSION_DEPTH {
+		ttScore, ttNodeType, ttDepth, ttMove, found := thread.engine.tt.probeEntry(plyFromRoot, thread.currentSearchTurn, board.GetTopState().ZobristKey)
+		// Only a lower bound says the TT move is good enough, a mate score leaves no room for the margin
+		if found && ttMove != NULL_MOVE && (ttNodeType == CUTnode || ttNodeType == PVnode) &&
+			ttDepth >= depth-SINGULAR_TT_DEPTH_SLACK && scoreIsCheckmate(ttScore) == 0 {
+			singularBeta := ttScore - SINGULAR_MARGIN*int(depth)
+
+			thread.excludedMoves[plyFromRoot] = ttMove
+			singularScore := thread.search((depth-1)/2, plyFromRoot, singularBeta-1, singularBeta, numExtensions, doNullMove)
+			thread.excludedMoves[plyFromRoot] = NULL_MOVE
+
+			if thread.stopped {
+				return thread.bestEvalThisIteration
+			}
+			if singularScore < singularBeta {
+				thread.latestSearchInfo.debug.singularExtensions++
+				singularMove, singularExtension = ttMove, 1
+			} else if singularBeta >= beta {
+				// Multi-Cut, https://www.chessprogramming.org/Multi-Cut
+				// even without the TT move a reduced search fails high, so several moves beat beta and the node is cut
+				thread.latestSearchInfo.debug.multiCuts++
+				return singularBeta
+			}
+		}
+	}
+
+	// A root searched without some of its moves must not be stored, its result is not the position's
+	restrictedRoot := plyFromRoot == 0 && (len(thread.searchedRootMoves) > 0 || len(thread.searchMoves) > 0)
+	picker := thread.newMovePicker(plyFromRoot, thread.savedPV[plyFromRoot], probeMove, excludedMove, restrictedRoot)
+	history := &picker.history
+
+	firstMove := picker.next()
+	if firstMove == NULL_MOVE {
+		if excludedMove != NULL_MOVE && board.isLegalMove(excludedMove) {
+			return alpha // The excluded move is the only move, so it is singular
+		}
+		thread.countLeafNode()
 		if board.InCheck() {
 			return MATE_SCORE + int(plyFromRoot) // Checkmate
 		} else {
 			return 0 // Stalemate
 		}
 	}
+	triedMoves := thread.triedMovePool[plyFromRoot][:0] // Moves searched so far, punished by the history once another move cuts
+	recordTT := !restrictedRoot && excludedMove == NULL_MOVE
 
 	if DebugMode {
 		switch probeNodeType {
 		case PVnode:
-			latestSearchInfo.debug.probePVNodes++
+			thread.latestSearchInfo.debug.probePVNodes++
 		case ALLnode:
-			latestSearchInfo.debug.probeALLNodes++
+			thread.latestSearchInfo.debug.probeALLNodes++
 		case CUTnode:
-			latestSearchInfo.debug.probeCUTNodes++
+			thread.latestSearchInfo.debug.probeCUTNodes++
 		}
 	}
 
 	nodeType := ALLnode
-	this_pvPtr := pvPtr
-	pv[pvPtr] = NULL_MOVE // initialize empty PV
-	pvPtr += int(MAX_POSSIBLE_DEPTH)
+	this_pvPtr := thread.pvPtr
+	thread.pv[thread.pvPtr] = NULL_MOVE // initialize empty PV
+	thread.pvPtr += int(MAX_POSSIBLE_DEPTH)
 
 	wasInCheck := board.InCheck()
 
+	// Futility Pruning, https://www.chessprogramming.org/Futility_Pruning
+	// at frontier nodes so far below alpha that a quiet move is not expected to raise it, only captures, promotions and checks are searched
+	futilityValue := staticEval + margins.Futility*int(depth)
+	futilityPruning := canPrune && depth <= FUTILITY_MAX_DEPTH && futilityValue <= alpha
+
 	var bestScore int
 
 	{
-		move := moveList[0]
+		move := firstMove
+		if plyFromRoot == 0 {
+			thread.reportRootMove(depth, move, 1)
+		}
 		// using fail soft with negamax:
 		board.MakeMove(move)
 		extension := extendSearch(board, move, numExtensions)
-		bestScore = -board.search(depth-1+extension, plyFromRoot+1, -beta, -alpha, numExtensions+extension, false, true, cancelChannel)
+		if move.enc == singularMove.enc {
+			extension = max(extension, singularExtension)
+		}
+		bestScore = -thread.search(depth-1+extension, plyFromRoot+1, -beta, -alpha, numExtensions+extension, true)
 		board.UnMakeMove()
 
-		// Check if the search has been cancelled
-		select {
-		case <-cancelChannel:
-			return bestEvalThisIteration
-		default:
+		// Check if the search has been stopped
+		if thread.stopped {
+			return thread.bestEvalThisIteration
 		}
 
 		if bestScore >= beta {
 			// If the score is greater than or equal to beta,
 			// it means that the opponent has a better move to choose.
 			// We record this information in the transposition table.
-			recordHash(depth, CUTnode, currentSearchTurn, bestScore, NULL_MOVE, board.GetTopState().ZobristKey)
-			pvPtr = this_pvPtr
+			if plyFromRoot == 0 { // Keeps the move an aspiration search failed high on
+				thread.bestEvalThisIteration = bestScore
+				thread.updatePVTable(this_pvPtr, move, depth)
+			}
+			if recordTT {
+				thread.engine.tt.recordHash(depth, plyFromRoot, CUTnode, thread.currentSearchTurn, bestScore, move, board.GetTopState().ZobristKey)
+			}
+			thread.pvPtr = this_pvPtr
 			// Killer Heuristic, https://www.chessprogramming.org/Killer_Heuristic
-			// if !board.InCheck() && move != savedPV[plyFromRoot] {
-			// 	updateKiller(plyFromRoot, move)
-			// }
+			if !wasInCheck && move.enc != thread.savedPV[plyFromRoot].enc {
+				thread.updateKiller(plyFromRoot, move)
+			}
 			// History Heuristic, https://www.chessprogramming.org/History_Heuristic
-			// No need to loop since this is the first move
-			updateHistory(board, move, int16(depth*depth))
+			// No moves were tried before the first one
+			thread.updateCutoffHistories(history, move, nil, depth)
 
 			if DebugMode {
-				latestSearchInfo.debug.cutNodes++
+				thread.latestSearchInfo.debug.cutNodes++
 				if depth == 1 {
-					latestSearchInfo.debug.D1cutNodes++
+					thread.latestSearchInfo.debug.D1cutNodes++
 				}
 				if probeNodeType == CUTnode {
-					latestSearchInfo.debug.probeCUTNodesCorrect++
+					thread.latestSearchInfo.debug.probeCUTNodesCorrect++
 				}
 			}
 			return bestScore
 		}
 		if bestScore > alpha { // This move is better than the current best move
 			if plyFromRoot == 0 {
-				bestEvalThisIteration = bestScore // Update the best evaluation score for this iteration
+				thread.bestEvalThisIteration = bestScore // Update the best evaluation score for this iteration
 			}
-			updatePVTable(this_pvPtr, move, depth)
+			thread.updatePVTable(this_pvPtr, move, depth)
 			nodeType = PVnode
 			alpha = bestScore
 		}
 	}
 
-	for i, move := range moveList[1:] {
+	triedMoves = append(triedMoves, firstMove)
+
+	for moveIndex := 1; ; moveIndex++ { // moveIndex counts the moves the picker returned before this one
+		move := picker.next()
+		if move == NULL_MOVE {
+			break
+		}
+		if plyFromRoot == 0 {
+			thread.reportRootMove(depth, move, moveIndex+1)
+		}
 
 		var score int
 		needFullSearch := true
 
 		if DebugMode {
-			latestSearchInfo.debug.siblingNodes++
+			thread.latestSearchInfo.debug.siblingNodes++
 		}
 
+		var moveHistory int16
+		if isQuietMove(move) {
+			moveHistory = thread.getQuietHistory(history, move)
+		}
 		board.MakeMove(move)
+		quiet := isQuietMove(move) && !board.InCheck()
+
+		if futilityPruning && quiet {
+			board.UnMakeMove()
+			thread.latestSearchInfo.debug.futilityPrunes++
+			bestScore = max(bestScore, futilityValue) // The skipped move is assumed to score no better than the futility value
+			continue
+		}
+
+		// Late Move Pruning, https://www.chessprogramming.org/Futility_Pruning#MoveCountBasedPruning
+		// good quiet moves are ordered early by the history, so at shallow depth the late ones are skipped
+		if canPrune && quiet && depth <= LATE_MOVE_PRUNING_DEPTH && moveIndex >= lateMovePruningCount(depth, improving) {
+			board.UnMakeMove()
+			thread.latestSearchInfo.debug.lmpPrunes++
+			continue
+		}
 
 		// Move extensions,
 		extension := extendSearch(board, move, numExtensions)
+		if move.enc == singularMove.enc {
+			extension = max(extension, singularExtension)
+		}
 
 		// Late move reductions, https://www.chessprogramming.org/Late_Move_Reductions
 		var reduceAmount int8
-		if depth >= 3 && !wasInCheck && !board.InCheck() && extension == 0 && !inPVNode && isQuietMove(move) && !searchReduced {
-			reduceAmount = depth / 3 // Senpai engine LMR implementation
+		if depth >= LMR_MIN_DEPTH && !wasInCheck && quiet && extension == 0 {
+			reduceAmount = lmrTable[depth][moveIndex]
+			if inPVNode {
+				reduceAmount--
+			}
+			if !improving {
+				reduceAmount++
+			}
+			reduceAmount -= int8(moveHistory / LMR_HISTORY_DIVISOR)
+			reduceAmount = max(0, min(reduceAmount, depth-2)) // Never drop straight into the quiescence search
+		}
+		if reduceAmount > 0 {
 			if DebugMode {
-				latestSearchInfo.debug.reducedNodes++
+				thread.latestSearchInfo.debug.reducedNodes++
+				thread.latestSearchInfo.debug.reductions[min(reduceAmount, LMR_MAX_REDUCTION_STATS)]++
 			}
-			latestSearchInfo.debug.amountReduced += uint64(reduceAmount)
-			score = -board.search(depth-1-reduceAmount, plyFromRoot+1, -alpha-1, -alpha, numExtensions+extension, true, true, cancelChannel)
-			needFullSearch = (score > alpha && score < beta)
+			thread.latestSearchInfo.debug.amountReduced += uint64(reduceAmount)
+			score = -thread.search(depth-1-reduceAmount, plyFromRoot+1, -alpha-1, -alpha, numExtensions+extension, true)
+			needFullSearch = score > alpha // A reduced move that beats alpha is verified at full depth
 		}
 
 		// PVS Search, https://www.chessprogramming.org/Principal_Variation_Search
 		if needFullSearch {
-			score = -board.search(depth-1+extension, plyFromRoot+1, -alpha-1, -alpha, numExtensions+extension, (reduceAmount != 0) || searchReduced, true, cancelChannel)
+			score = -thread.search(depth-1+extension, plyFromRoot+1, -alpha-1, -alpha, numExtensions+extension, true)
 			if DebugMode && reduceAmount != 0 {
-				latestSearchInfo.debug.researchedReduceNodes++
+				thread.latestSearchInfo.debug.researchedReduceNodes++
 			}
 			needFullSearch = (score > alpha && score < beta)
 		}
 
 		// Full search
 		if needFullSearch {
-			score = -board.search(depth-1+extension, plyFromRoot+1, -beta, -alpha, numExtensions+extension, false, true, cancelChannel)
+			score = -thread.search(depth-1+extension, plyFromRoot+1, -beta, -alpha, numExtensions+extension, true)
 			if DebugMode {
-				latestSearchInfo.debug.researchedNodes++
+				thread.latestSearchInfo.debug.researchedNodes++
 			}
 			alpha = max(alpha, score)
 		}
 
 		board.UnMakeMove()
 
-		// Check if the search has been cancelled
-		select {
-		case <-cancelChannel:
-			return bestEvalThisIteration
-		default:
+		// Check if the search has been stopped
+		if thread.stopped {
+			return thread.bestEvalThisIteration
 		}
 
 		if score >= beta {
-			recordHash(depth, CUTnode, currentSearchTurn, score, NULL_MOVE, board.GetTopState().ZobristKey)
-			pvPtr = this_pvPtr
+			if plyFromRoot == 0 { // Keeps the move an aspiration search failed high on
+				thread.bestEvalThisIteration = score
+				thread.updatePVTable(this_pvPtr, move, depth)
+			}
+			if recordTT {
+				thread.engine.tt.recordHash(depth, plyFromRoot, CUTnode, thread.currentSearchTurn, score, move, board.GetTopState().ZobristKey)
+			}
+			thread.pvPtr = this_pvPtr
 			if DebugMode {
-				latestSearchInfo.debug.cutNodes++
+				thread.latestSearchInfo.debug.cutNodes++
 				if depth == 1 {
-					latestSearchInfo.debug.D1cutNodes++
+					thread.latestSearchInfo.debug.D1cutNodes++
 				}
 				if probeNodeType == CUTnode {
-					latestSearchInfo.debug.probeCUTNodesCorrect++
+					thread.latestSearchInfo.debug.probeCUTNodesCorrect++
 				}
 			}
 			// Killer Heuristic, https://www.chessprogramming.org/Killer_Heuristic
-			// if !board.InCheck() && move != savedPV[plyFromRoot] {
-			// 	updateKiller(plyFromRoot, move)
-			// }
-			// History Heuristic, https://www.chessprogramming.org/History_Heuristic
-			if updateHistory(board, move, int16(depth*depth)) { // If this move is a quiet move
-				// Update all quiet moves before this move to have negative value
-				for j := i - 1; j >= 0 && updateHistory(board, moveList[j], -int16(depth*depth)); j-- {
-				}
+			if !wasInCheck && move.enc != thread.savedPV[plyFromRoot].enc {
+				thread.updateKiller(plyFromRoot, move)
 			}
+			// History Heuristic, https://www.chessprogramming.org/History_Heuristic
+			thread.updateCutoffHistories(history, move, triedMoves, depth)
 			return score
 		}
 		if score > bestScore { // This move is better than the current best move
 			if plyFromRoot == 0 {
-				bestEvalThisIteration = score // Update the best evaluation score for this iteration
+				thread.bestEvalThisIteration = score // Update the best evaluation score for this iteration
 			}
-			updatePVTable(this_pvPtr, move, depth)
+			thread.updatePVTable(this_pvPtr, move, depth)
 			nodeType = PVnode
 			bestScore = score
 		}
+		triedMoves = append(triedMoves, move)
 	}
 	if DebugMode {
 		if nodeType == ALLnode {
-			latestSearchInfo.debug.allNodes++
+			thread.latestSearchInfo.debug.allNodes++
 			if depth == 1 {
-				latestSearchInfo.debug.D1allNodes++
+				thread.latestSearchInfo.debug.D1allNodes++
 			}
 			if probeNodeType == ALLnode {
-				latestSearchInfo.debug.probeALLNodesCorrect++
+				thread.latestSearchInfo.debug.probeALLNodesCorrect++
 			}
 		} else if nodeType == PVnode {
-			latestSearchInfo.debug.pvNodes++
+			thread.latestSearchInfo.debug.pvNodes++
 			if depth == 1 {
-				latestSearchInfo.debug.D1pvNodes++
+				thread.latestSearchInfo.debug.D1pvNodes++
 			}
 			if probeNodeType == PVnode {
-				latestSearchInfo.debug.probePVNodesCorrect++
+				thread.latestSearchInfo.debug.probePVNodesCorrect++
+			}
+		}
+	}
+
+	thread.pvPtr = this_pvPtr
+	if recordTT {
+		thread.engine.tt.recordHash(depth, plyFromRoot, nodeType, thread.currentSearchTurn, bestScore, thread.pv[this_pvPtr], board.GetTopState().ZobristKey) // Record the best move for this position
+	}
+	return bestScore
+}
+
+/*
+Searches the root in a window around the score the same line had in the previous iteration, https://www.chessprogramming.org/Aspiration_Windows
+the window is widened on the failing side until the score lands inside it. Fail highs and lows are reported as bounds.
+Once stopped, the score is the one of the root move in the PV, which is only exact if it landed inside the window of the unfinished search
+*/
+func (thread *searchThread) aspirationSearch(depth int8, lineIndex int) (int, ScoreBound) {
+	alpha, beta := MIN_VALUE, MAX_VALUE
+	delta := ASPIRATION_WINDOW
+	// Near mate the score jumps with every ply, so a window would only cost re-searches
+	if depth >= ASPIRATION_MIN_DEPTH && lineIndex < len(thread.multiPVLines) && scoreIsCheckmate(thread.multiPVLines[lineIndex].Score) == 0 {
+		prevScore := thread.multiPVLines[lineIndex].Score
+		alpha, beta = prevScore-delta, prevScore+delta
+	}
+
+	for {
+		thread.pv = [squareTableSize]Move{}
+		score := thread.search(depth, 0, alpha, beta, 0, true)
+		if thread.stopped {
+			score = thread.bestEvalThisIteration
+			if score <= alpha {
+				return score, UpperBound
+			} else if score >= beta {
+				return score, LowerBound
+			}
+		}
+		if thread.stopped || (score > alpha && score < beta) {
+			return score, ExactBound
+		}
+
+		if score <= alpha {
+			alpha = max(MIN_VALUE, score-delta)
+			thread.reportBound(lineIndex, PVLine{Score: score, Bound: UpperBound, Depth: depth, SelDepth: depth + thread.latestSearchInfo.seldepth})
+		} else {
+			beta = min(MAX_VALUE, score+delta)
+			thread.reportBound(lineIndex, thread.newPVLine(score, LowerBound, depth))
+		}
+		delta *= 2
+		if delta > ASPIRATION_MAX_WINDOW || scoreIsCheckmate(score) != 0 {
+			alpha, beta = MIN_VALUE, MAX_VALUE
+		}
+	}
+}
+
+// Reports a fail high or low of the main thread's aspiration search, lines without a PV of their own show the previous one
+func (thread *searchThread) reportBound(lineIndex int, line PVLine) {
+	if thread.id != 0 || thread.engine.observer == nil {
+		return
+	}
+	if len(line.PV) == 0 && lineIndex < len(thread.multiPVLines) {
+		line.PV = thread.multiPVLines[lineIndex].PV
+	}
+	if len(line.PV) > 0 {
+		thread.engine.observer.Iteration(thread.iterationInfo(lineIndex+1, line))
+	}
+}
+
+/*
+Mate search used by "go mate", https://www.chessprogramming.org/Mate_Search
+Every line that does not end in mate within depth scores as a draw, so once one move fails to mate
+alpha-beta cuts every reply that holds the draw. The search has no quiescence search, extensions or reductions
+that could make it miss a mate or report one beyond the bound, and on the side to move's last move only checks are tried
+*/
+func (thread *searchThread) mateSearch(depth, plyFromRoot int8, alpha, beta int) int {
+	board := thread.board
+	thread.pv[thread.pvPtr] = NULL_MOVE
+
+	// Check if the search has been stopped
+	if thread.stopped {
+		return thread.bestEvalThisIteration
+	}
+	thread.countNode()
+
+	if plyFromRoot > 0 {
+		// Fifty move rule, Insufficient Material, Repetition
+		if board.GetTopState().HalfMoveClock >= 100 ||
+			isInsufficientMaterial(board) ||
+			board.isRepetition(plyFromRoot) {
+			return DRAW_SCORE
+		}
+	}
+
+	moveList := board.GenerateMoves(ALL, thread.searchMovePool[plyFromRoot][:0])
+	if len(moveList) == 0 {
+		if board.InCheck() {
+			return MATE_SCORE + int(plyFromRoot) // Checkmate
+		}
+		return DRAW_SCORE // Stalemate
+	}
+	if depth <= 0 {
+		return DRAW_SCORE // No mate within the bound
+	}
+	if plyFromRoot == 0 && len(thread.searchedRootMoves)+len(thread.searchMoves) > 0 {
+		moveList = thread.filterRootMoves(moveList)
+	}
+	thread.moveordering(thread.savedPV[plyFromRoot], NULL_MOVE, plyFromRoot, moveList)
+
+	this_pvPtr := thread.pvPtr
+	thread.pv[this_pvPtr] = NULL_MOVE // initialize empty PV
+	thread.pvPtr += int(MAX_POSSIBLE_DEPTH)
+
+	bestScore := MIN_VALUE
+	for i, move := range moveList {
+		if plyFromRoot == 0 {
+			thread.reportRootMove(depth, move, i+1)
+		}
+		board.MakeMove(move)
+		// Only a check can mate on the last move, the root searches every move so it always has a PV
+		if depth == 1 && plyFromRoot > 0 && !board.InCheck() {
+			board.UnMakeMove()
+			continue
+		}
+		score := -thread.mateSearch(depth-1, plyFromRoot+1, -beta, -alpha)
+		board.UnMakeMove()
+
+		// Check if the search has been stopped
+		if thread.stopped {
+			return thread.bestEvalThisIteration
+		}
+
+		if score > bestScore {
+			bestScore = score
+			if score > alpha {
+				alpha = score
+				if plyFromRoot == 0 {
+					thread.bestEvalThisIteration = score
+				}
+				thread.updatePVTable(this_pvPtr, move, depth)
+			}
+			if score >= beta {
+				break
 			}
 		}
 	}
+	thread.pvPtr = this_pvPtr
 
-	pvPtr = this_pvPtr
-	recordHash(depth, nodeType, currentSearchTurn, bestScore, pv[this_pvPtr], board.GetTopState().ZobristKey) // Record the best move for this position
+	if bestScore == MIN_VALUE { // None of the last moves gave check
+		return DRAW_SCORE
+	}
 	return bestScore
 }
 
-func (board *Board) quiescenceSearch(alpha, beta int, plyFromSearch int8, cancelChannel chan struct{}) int {
-	select { // Check if the search has been cancelled
-	case <-cancelChannel:
-		return bestEvalThisIteration
-	default:
+func (thread *searchThread) quiescenceSearch(alpha, beta int, plyFromRoot, plyFromSearch int8) int {
+	board := thread.board
+
+	if thread.stopped { // Check if the search has been stopped
+		return thread.bestEvalThisIteration
+	}
+	if plyFromSearch > 0 { // The first quiescence node was already counted by search
+		thread.countNode()
 	}
 
-	latestSearchInfo.debug.qNodes++
+	thread.latestSearchInfo.debug.qNodes++
+
+	// In check standing pat is not an option, every evasion is searched instead, https://www.chessprogramming.org/Quiescence_Search#Checks
+	inCheck := board.InCheck()
 
 	eval, mgPhase, egPhase := board.Evaluate()
-	if eval >= beta {
+	if eval >= beta && !inCheck {
 		return beta
 	}
 
@@ -407,28 +989,69 @@ func (board *Board) quiescenceSearch(alpha, beta int, plyFromSearch int8, cancel
 	*/
 	deltaPrune := alpha - board.EvaluateMaterial(mgPhase, egPhase) - DELTAPRUNE_MARGIN
 
-	if eval > alpha {
+	if eval > alpha && !inCheck {
 		alpha = eval
 	}
 
 	if plyFromSearch >= MAX_QSEARCH_DEPTH {
-		return alpha
+		return max(alpha, eval)
+	}
+
+	thread.latestSearchInfo.seldepth = max(plyFromSearch, thread.latestSearchInfo.seldepth)
+
+	var moveList []Move
+	if inCheck {
+		moveList = board.GenerateMoves(EVASION, thread.qsearchMovePool[plyFromSearch][:0])
+		if len(moveList) == 0 {
+			return MATE_SCORE + int(plyFromRoot+plyFromSearch) // Checkmate
+		}
+	} else {
+		moveList = board.GenerateMoves(CAPTURE, thread.qsearchMovePool[plyFromSearch][:0])
 	}
+	board.quiescence_moveordering(moveList)
+
+	for _, move := range moveList {
+		if !inCheck {
+			// Delta pruning cut
+			if mgPhase > LATE_GAME_PHASE_CUTOFF && getTargetPieceValue(board, move, egPhase) < deltaPrune {
+				thread.latestSearchInfo.debug.qNodeDeltaPrunes++
+				continue
+			}
+			// Captures losing material cannot raise alpha once the opponent recaptures, https://www.chessprogramming.org/Static_Exchange_Evaluation
+			if isBadCapture(move) {
+				thread.latestSearchInfo.debug.qNodeSEEPrunes++
+				continue
+			}
+		}
 
-	latestSearchInfo.seldepth = max(plyFromSearch, latestSearchInfo.seldepth)
+		board.MakeMove(move)
+		eval := -thread.quiescenceSearch(-beta, -alpha, plyFromRoot, plyFromSearch+1)
+		board.UnMakeMove()
 
-	captureMoveList := board.GenerateMoves(CAPTURE, qsearchMovePool[plyFromSearch][:0])
-	board.quiescence_moveordering(captureMoveList)
+		if eval >= beta {
+			return beta
+		}
+		if eval > alpha {
+			alpha = eval
+		}
+	}
 
-	for _, move := range captureMoveList {
-		// Delta pruning cut
-		if mgPhase > LATE_GAME_PHASE_CUTOFF && getTargetPieceValue(board, move, egPhase) < deltaPrune {
-			latestSearchInfo.debug.qNodeDeltaPrunes++
+	// Quiet checks are only tried on the first ply, deeper they would make the quiescence search explode
+	if inCheck || plyFromSearch > 0 {
+		return alpha
+	}
+	for _, move := range board.GenerateMoves(QUIET, moveList[len(moveList):]) {
+		if !board.givesCheck(move) {
+			continue
+		}
+		// A check giving away the checking piece is not worth it
+		if board.SEE(move) < 0 {
+			thread.latestSearchInfo.debug.qNodeSEEPrunes++
 			continue
 		}
 
 		board.MakeMove(move)
-		eval := -board.quiescenceSearch(-beta, -alpha, plyFromSearch+1, cancelChannel)
+		eval := -thread.quiescenceSearch(-beta, -alpha, plyFromRoot, plyFromSearch+1)
 		board.UnMakeMove()
 
 		if eval >= beta {
@@ -483,69 +1106,53 @@ func getTargetPieceValue(board *Board, move Move, gamePhase int) int {
 		gamePhase)
 }
 
-func updatePVTable(this_pvPtr int, move Move, depth int8) {
+func (thread *searchThread) updatePVTable(this_pvPtr int, move Move, depth int8) {
 	if move.enc == NULL_MOVE.enc {
 		panic("PV Table Invalid Update w/ Null move")
 	}
-	child_pvPtr := pvPtr
+	child_pvPtr := thread.pvPtr
 	tempPtr := this_pvPtr
-	pv[tempPtr] = move
+	thread.pv[tempPtr] = move
 	tempPtr++
-	for i := int8(0); i < depth-1 && pv[child_pvPtr] != NULL_MOVE; i++ { // copy child PV behind it
-		pv[tempPtr] = pv[child_pvPtr]
+	for i := int8(0); i < depth-1 && thread.pv[child_pvPtr] != NULL_MOVE; i++ { // copy child PV behind it
+		thread.pv[tempPtr] = thread.pv[child_pvPtr]
 		tempPtr++
 		child_pvPtr++
 	}
+	if tempPtr < this_pvPtr+int(MAX_POSSIBLE_DEPTH) {
+		thread.pv[tempPtr] = NULL_MOVE // Terminate the line, so a longer line stored here earlier does not leak into it
+	}
 }
 
-/*
-	Outputs the engine info from a given search depth following the format:
-
-info depth <depth> seldepth <maxdepth searched> multipv <principal variations> score cp <score> nodes <nodecount> nps <nodes / time> time <time taken in ms> pv <pv>
-*/
-func engineInfoString() (retval string) {
-	elapsedTime := time.Since(latestSearchInfo.startTime)
-	nps := int64(float64(latestSearchInfo.leafNodes) / elapsedTime.Seconds())
-	hashFill := int(float64(DebugTableSize) / float64(TableCapacity) * 1000)
-	// Convert PV chain up to depth to a single string seperated by " "
-	pvString := ""
-	for i := int8(0); i < latestSearchInfo.depth; i++ {
-		if savedPV[i] == NULL_MOVE {
-			break
+// Outputs the node statistics of the latest iteration, only gathered in DebugMode
+func (thread *searchThread) debugInfoString() (retval string) {
+	info := &thread.latestSearchInfo
+	totalNodeCount := info.debug.allNodes + info.debug.pvNodes + info.debug.cutNodes
+	D1totalNodeCount := info.debug.D1allNodes + info.debug.D1pvNodes + info.debug.D1cutNodes
+	retval += fmt.Sprintf("\nDebug Info of nodes:\n\tpvNodes: %d(%0.2f%%)\n\tallNodes: %d(%0.2f%%)\n\tcutNodes: %d(%0.2f%%)\n", info.debug.pvNodes, 100*float32(info.debug.pvNodes)/float32(totalNodeCount), info.debug.allNodes, 100*float32(info.debug.allNodes)/float32(totalNodeCount), info.debug.cutNodes, 100*float32(info.debug.cutNodes)/float32(totalNodeCount))
+	retval += fmt.Sprintf("\nDebug Info of depth-1 nodes:\n\tpvNodes: %d(%0.2f%%)\n\tallNodes: %d(%0.2f%%)\n\tcutNodes: %d(%0.2f%%)\n", info.debug.D1pvNodes, 100*float32(info.debug.D1pvNodes)/float32(D1totalNodeCount), info.debug.D1allNodes, 100*float32(info.debug.D1allNodes)/float32(D1totalNodeCount), info.debug.D1cutNodes, 100*float32(info.debug.D1cutNodes)/float32(D1totalNodeCount))
+	retval += fmt.Sprintf("\nDebug Info of probe nodes:\n\tpvNodes: %d(Correct: %0.2f%%)\n\tallNodes: %d(Correct: %0.2f%%)\n\tcutNodes: %d(Correct: %0.2f%%)\n", info.debug.probePVNodes, 100*float32(info.debug.probePVNodesCorrect)/float32(info.debug.probePVNodes), info.debug.probeALLNodes, 100*float32(info.debug.probeALLNodesCorrect)/float32(info.debug.probeALLNodes), info.debug.probeCUTNodes, 100*float32(info.debug.probeCUTNodesCorrect)/float32(info.debug.probeCUTNodes))
+	retval += fmt.Sprintf("\nDebug Info of quiescence nodes:\n\tqNodes: %d\n\tqNodes delta Pruned: %d(%0.2f%%)\n\tqNodes SEE Pruned: %d(%0.2f%%)\n", info.debug.qNodes-info.leafNodes, info.debug.qNodeDeltaPrunes, 100*float32(info.debug.qNodeDeltaPrunes)/float32(info.debug.qNodes-info.leafNodes), info.debug.qNodeSEEPrunes, 100*float32(info.debug.qNodeSEEPrunes)/float32(info.debug.qNodes-info.leafNodes))
+	retval += fmt.Sprintf("\nDebug Info of sibling nodes:\n\tsiblingNodes: %d\n\tsiblingNodes re-searched: %d(%0.2f%%)\n", info.debug.siblingNodes, info.debug.researchedNodes, 100*float32(info.debug.researchedNodes)/float32(info.debug.siblingNodes))
+	retval += fmt.Sprintf("\nDebug Info of reduced nodes:\n\treducedNodes: %d(%0.2f%%)\n\taverage Reduce Amount: %0.2f\n\treducedNodes re-searched: %d(%0.2f%%)\n", info.debug.reducedNodes, 100*float32(info.debug.reducedNodes)/float32(info.debug.siblingNodes), float32(info.debug.amountReduced)/float32(info.debug.reducedNodes), info.debug.researchedReduceNodes, 100*float32(info.debug.researchedReduceNodes)/float32(info.debug.reducedNodes))
+	retval += "\tReduction distribution:\n"
+	for reduction := 1; reduction <= LMR_MAX_REDUCTION_STATS; reduction++ {
+		plus := ""
+		if reduction == LMR_MAX_REDUCTION_STATS {
+			plus = "+"
 		}
-		pvString += MoveToString(savedPV[i]) + " "
+		retval += fmt.Sprintf("\t\tR=%d%s: %d(%0.2f%%)\n", reduction, plus, info.debug.reductions[reduction], 100*float32(info.debug.reductions[reduction])/float32(info.debug.reducedNodes))
 	}
-	// Strip surrounding whitespace from PV string
-	pvString = pvString[:len(pvString)-1]
-	checkmateScore := scoreIsCheckmate(latestSearchInfo.score)
-
-	if checkmateScore != 0 {
-		retval += fmt.Sprintf("info depth %d seldepth %d multipv %d score mate %d nodes %d nps %d hashfull %d time %d pv %s",
-			latestSearchInfo.depth, latestSearchInfo.seldepth+latestSearchInfo.depth, latestSearchInfo.multipv,
-			checkmateScore, latestSearchInfo.leafNodes, nps, hashFill, elapsedTime.Milliseconds(), pvString)
-	} else {
-		retval += fmt.Sprintf("info depth %d seldepth %d multipv %d score cp %d nodes %d nps %d hashfull %d time %d pv %s",
-			latestSearchInfo.depth, latestSearchInfo.seldepth+latestSearchInfo.depth, latestSearchInfo.multipv,
-			latestSearchInfo.score, latestSearchInfo.leafNodes, nps, hashFill, elapsedTime.Milliseconds(), pvString)
-	}
-
-	if DebugMode {
-		totalNodeCount := latestSearchInfo.debug.allNodes + latestSearchInfo.debug.pvNodes + latestSearchInfo.debug.cutNodes
-		D1totalNodeCount := latestSearchInfo.debug.D1allNodes + latestSearchInfo.debug.D1pvNodes + latestSearchInfo.debug.D1cutNodes
-		retval += fmt.Sprintf("\nDebug Info of nodes:\n\tpvNodes: %d(%0.2f%%)\n\tallNodes: %d(%0.2f%%)\n\tcutNodes: %d(%0.2f%%)\n", latestSearchInfo.debug.pvNodes, 100*float32(latestSearchInfo.debug.pvNodes)/float32(totalNodeCount), latestSearchInfo.debug.allNodes, 100*float32(latestSearchInfo.debug.allNodes)/float32(totalNodeCount), latestSearchInfo.debug.cutNodes, 100*float32(latestSearchInfo.debug.cutNodes)/float32(totalNodeCount))
-		retval += fmt.Sprintf("\nDebug Info of depth-1 nodes:\n\tpvNodes: %d(%0.2f%%)\n\tallNodes: %d(%0.2f%%)\n\tcutNodes: %d(%0.2f%%)\n", latestSearchInfo.debug.D1pvNodes, 100*float32(latestSearchInfo.debug.D1pvNodes)/float32(D1totalNodeCount), latestSearchInfo.debug.D1allNodes, 100*float32(latestSearchInfo.debug.D1allNodes)/float32(D1totalNodeCount), latestSearchInfo.debug.D1cutNodes, 100*float32(latestSearchInfo.debug.D1cutNodes)/float32(D1totalNodeCount))
-		retval += fmt.Sprintf("\nDebug Info of probe nodes:\n\tpvNodes: %d(Correct: %0.2f%%)\n\tallNodes: %d(Correct: %0.2f%%)\n\tcutNodes: %d(Correct: %0.2f%%)\n", latestSearchInfo.debug.probePVNodes, 100*float32(latestSearchInfo.debug.probePVNodesCorrect)/float32(latestSearchInfo.debug.probePVNodes), latestSearchInfo.debug.probeALLNodes, 100*float32(latestSearchInfo.debug.probeALLNodesCorrect)/float32(latestSearchInfo.debug.probeALLNodes), latestSearchInfo.debug.probeCUTNodes, 100*float32(latestSearchInfo.debug.probeCUTNodesCorrect)/float32(latestSearchInfo.debug.probeCUTNodes))
-		retval += fmt.Sprintf("\nDebug Info of quiescence nodes:\n\tqNodes: %d\n\tqNodes delta Pruned: %d(%0.2f%%)\n", latestSearchInfo.debug.qNodes-latestSearchInfo.leafNodes, latestSearchInfo.debug.qNodeDeltaPrunes, 100*float32(latestSearchInfo.debug.qNodeDeltaPrunes)/float32(latestSearchInfo.debug.qNodes-latestSearchInfo.leafNodes))
-		retval += fmt.Sprintf("\nDebug Info of sibling nodes:\n\tsiblingNodes: %d\n\tsiblingNodes re-searched: %d(%0.2f%%)\n", latestSearchInfo.debug.siblingNodes, latestSearchInfo.debug.researchedNodes, 100*float32(latestSearchInfo.debug.researchedNodes)/float32(latestSearchInfo.debug.siblingNodes))
-		retval += fmt.Sprintf("\nDebug Info of reduced nodes:\n\treducedNodes: %d(%0.2f%%)\n\taverage Reduce Amount: %0.2f\n\treducedNodes re-searched: %d(%0.2f%%)\n", latestSearchInfo.debug.reducedNodes, 100*float32(latestSearchInfo.debug.reducedNodes)/float32(latestSearchInfo.debug.siblingNodes), float32(latestSearchInfo.debug.amountReduced)/float32(latestSearchInfo.debug.reducedNodes), latestSearchInfo.debug.researchedReduceNodes, 100*float32(latestSearchInfo.debug.researchedReduceNodes)/float32(latestSearchInfo.debug.reducedNodes))
-		if latestSearchInfo.depth > 1 {
-			// Return branching factor in relation to previous iteration
-			retval += fmt.Sprintf("\nDebug Info of Effective Branching Factor:\n\t( N(D) / N(D-1) )\n\t%d/%d(%0.2f)\n", totalNodeCount, prevIterationNodeCount, float32(totalNodeCount)/float32(prevIterationNodeCount))
-		}
-		// Mean/Average Branching Factor
-		retval += fmt.Sprintf("\nDebug Info of Average Branching Factor:\n\t # of all nodes / # of non terminal nodes\n\t%d/%d(%0.2f)\n", totalNodeCount+latestSearchInfo.leafNodes, totalNodeCount, float32(totalNodeCount+latestSearchInfo.leafNodes)/float32(totalNodeCount))
-		prevIterationNodeCount = totalNodeCount
+	retval += fmt.Sprintf("\nDebug Info of shallow depth pruning:\n\treverse futility cuts: %d\n\trazoring cuts: %d\n\tfutility pruned moves: %d(%0.2f%%)\n\tlate move pruned moves: %d(%0.2f%%)\n", info.debug.reverseFutilityCuts, info.debug.razorCuts, info.debug.futilityPrunes, 100*float32(info.debug.futilityPrunes)/float32(info.debug.siblingNodes), info.debug.lmpPrunes, 100*float32(info.debug.lmpPrunes)/float32(info.debug.siblingNodes))
+	retval += fmt.Sprintf("\nDebug Info of singular extensions:\n\tsingular extensions: %d\n\tmulti-cuts: %d\n", info.debug.singularExtensions, info.debug.multiCuts)
+	retval += fmt.Sprintf("\nDebug Info of nodes without a TT move:\n\tIIR reductions: %d\n", info.debug.iirReductions)
+	if info.depth > 1 {
+		// Return branching factor in relation to previous iteration
+		retval += fmt.Sprintf("\nDebug Info of Effective Branching Factor:\n\t( N(D) / N(D-1) )\n\t%d/%d(%0.2f)\n", totalNodeCount, thread.prevIterationNodeCount, float32(totalNodeCount)/float32(thread.prevIterationNodeCount))
 	}
+	// Mean/Average Branching Factor
+	retval += fmt.Sprintf("\nDebug Info of Average Branching Factor:\n\t # of all nodes / # of non terminal nodes\n\t%d/%d(%0.2f)\n", totalNodeCount+info.leafNodes, totalNodeCount, float32(totalNodeCount+info.leafNodes)/float32(totalNodeCount))
+	thread.prevIterationNodeCount = totalNodeCount
 
 	return retval
 }
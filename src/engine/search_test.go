@@ -1,17 +1,45 @@
 package chessengine
 
 import (
+	timemanager "chessengine/src/timemanager"
+	"io"
+	"os"
+	"sync"
 	"testing"
 	"time"
 )
 
+// Records the progress of a search and logs its lines to the test output
+type testObserver struct {
+	t          *testing.T
+	iterations []IterationInfo
+	rootMoves  []RootMoveInfo
+}
+
+func (observer *testObserver) Iteration(info IterationInfo) {
+	observer.iterations = append(observer.iterations, info)
+	pv := ""
+	for _, move := range info.Line.PV {
+		pv += " " + MoveToString(move)
+	}
+	observer.t.Logf("depth %d seldepth %d multipv %d score %s nodes %d time %v pv%s",
+		info.Line.Depth, info.Line.SelDepth, info.MultiPV, info.Line.ScoreString(), info.Nodes, info.Time, pv)
+	if info.DebugInfo != "" {
+		observer.t.Log(info.DebugInfo)
+	}
+}
+
+func (observer *testObserver) RootMove(info RootMoveInfo) {
+	observer.rootMoves = append(observer.rootMoves, info)
+}
+
 func Test_SearchStartPosition_20s(t *testing.T) {
 	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
 	InitZobristTable()
 	InitPeSTO()
 
 	test := InitStartBoard()
-	TTReset(test, DefaultTTMBSize)
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
 	DebugMode = true
 	cancelChannel := make(chan struct{})
 
@@ -20,7 +48,7 @@ func Test_SearchStartPosition_20s(t *testing.T) {
 		time.Sleep(time.Duration(20000) * time.Millisecond)
 		close(cancelChannel)
 	}()
-	test.StartSearchNoDepth(startTime, cancelChannel)
+	searchEngine.Search(test, SearchLimits{StartTime: startTime, CancelChannel: cancelChannel, Observer: &testObserver{t: t}})
 	DebugMode = false
 }
 
@@ -30,7 +58,7 @@ func Test_SearchPosition1_20s(t *testing.T) {
 	InitPeSTO()
 
 	test := InitFENBoard("qrb5/rk1p1K2/p2P4/Pp6/1N2n3/6p1/5nB1/6b1 w - - 0 1")
-	TTReset(test, DefaultTTMBSize)
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
 	DebugMode = true
 	cancelChannel := make(chan struct{})
 
@@ -39,7 +67,7 @@ func Test_SearchPosition1_20s(t *testing.T) {
 		time.Sleep(time.Duration(20000) * time.Millisecond)
 		close(cancelChannel)
 	}()
-	test.StartSearchNoDepth(startTime, cancelChannel)
+	searchEngine.Search(test, SearchLimits{StartTime: startTime, CancelChannel: cancelChannel, Observer: &testObserver{t: t}})
 	DebugMode = false
 }
 
@@ -49,7 +77,7 @@ func Test_SearchPosition2_20s(t *testing.T) {
 	InitPeSTO()
 
 	test := InitFENBoard("rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8")
-	TTReset(test, DefaultTTMBSize)
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
 	DebugMode = true
 	cancelChannel := make(chan struct{})
 
@@ -58,6 +86,465 @@ func Test_SearchPosition2_20s(t *testing.T) {
 		time.Sleep(time.Duration(20000) * time.Millisecond)
 		close(cancelChannel)
 	}()
-	test.StartSearchNoDepth(startTime, cancelChannel)
+	searchEngine.Search(test, SearchLimits{StartTime: startTime, CancelChannel: cancelChannel, Observer: &testObserver{t: t}})
 	DebugMode = false
 }
+
+func Test_SearchLazySMP(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	test := InitFENBoard("rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8")
+	searchEngine := NewEngine(DefaultTTMBSize, 4)
+
+	result := searchEngine.Search(test, SearchLimits{Depth: 6})
+	if _, ok := test.TryMoveUCI(MoveToString(result.BestMove)); !ok {
+		t.Fatalf("Lazy SMP search returned an illegal move: %s", MoveToString(result.BestMove))
+	}
+	if result.Nodes == 0 {
+		t.Fatalf("Lazy SMP search reported no nodes")
+	}
+	// The ponder move is the expected reply to the best move
+	bestMove, _ := test.TryMoveUCI(MoveToString(result.BestMove))
+	test.MakeMove(bestMove)
+	if _, ok := test.TryMoveUCI(MoveToString(result.PonderMove)); !ok {
+		t.Fatalf("Lazy SMP search returned an illegal ponder move: %s %s", MoveToString(result.BestMove), MoveToString(result.PonderMove))
+	}
+	test.UnMakeMove()
+	// Helper threads search their own copies, the root board must be left untouched
+	if !test.Equal(InitFENBoard("rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8")) {
+		t.Fatalf("Lazy SMP search modified the root board\n%s", test.DisplayBoard())
+	}
+}
+
+// Threads read and write the shared table without locks, meant to be run with -race as well
+func Test_SearchPonderMoveFromTT(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	test := InitStartBoard()
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	bestMove, _ := test.TryMoveUCI("e2e4")
+	searchEngine.threads[0].savedPV = [MAX_POSSIBLE_DEPTH]Move{bestMove} // A PV cut short right after the best move
+
+	test.MakeMove(bestMove)
+	reply, _ := test.TryMoveUCI("c7c5")
+	afterKey := test.GetTopState().ZobristKey
+	test.UnMakeMove()
+	turn := test.GetTopState().TurnCounter
+
+	if move := searchEngine.ponderMove(test, bestMove); move != NULL_MOVE {
+		t.Fatalf("Ponder move %s without a PV or TT entry", MoveToString(move))
+	}
+	searchEngine.tt.recordHash(3, 1, CUTnode, turn, 20, reply, afterKey)
+	if move := searchEngine.ponderMove(test, bestMove); move.enc != reply.enc {
+		t.Fatalf("Ponder move %s, wanted the TT move %s", MoveToString(move), MoveToString(reply))
+	}
+	// A colliding entry may hold a move that is illegal after the best move
+	illegal, _ := test.TryMoveUCI("g1f3")
+	searchEngine.tt.recordHash(4, 1, CUTnode, turn, 20, illegal, afterKey)
+	if move := searchEngine.ponderMove(test, bestMove); move != NULL_MOVE {
+		t.Fatalf("Illegal TT move %s returned as the ponder move", MoveToString(move))
+	}
+	if !test.Equal(InitStartBoard()) {
+		t.Fatalf("Probing the ponder move modified the board\n%s", test.DisplayBoard())
+	}
+}
+
+func Test_SearchLazySMPSharedTable(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	fens := []string{
+		StartingFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"3r1k2/4npp1/1ppr3p/p6P/P2PPPP1/1NR5/5K2/2R5 w - - 0 1",
+	}
+	searchEngine := NewEngine(1, 8) // A small table so that the threads keep replacing each other's entries
+	DebugMode = true
+	defer func() { DebugMode = false }()
+	for _, fen := range fens {
+		test := InitFENBoard(fen)
+		result := searchEngine.Search(test, SearchLimits{Depth: 7})
+		if _, ok := test.TryMoveUCI(MoveToString(result.BestMove)); !ok {
+			t.Fatalf("Lazy SMP search returned an illegal move in %s: %s", fen, MoveToString(result.BestMove))
+		}
+		if hashFull := searchEngine.tt.hashFull(); hashFull <= 0 || hashFull > 1000 {
+			t.Fatalf("Hashfull %d after searching %s", hashFull, fen)
+		}
+	}
+}
+
+func Test_SearchIndependentEngines(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	fens := []string{
+		StartingFen,
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"3r1k2/4npp1/1ppr3p/p6P/P2PPPP1/1NR5/5K2/2R5 w - - 0 1",
+	}
+
+	// Reference results, one engine searching after another
+	expected := make([]SearchResult, len(fens))
+	for i, fen := range fens {
+		expected[i] = NewEngine(DefaultTTMBSize, 1).Search(InitFENBoard(fen), SearchLimits{Depth: 5})
+	}
+
+	// The same searches run at once on separate engines must not interfere with each other
+	results := make([]SearchResult, len(fens))
+	var wg sync.WaitGroup
+	for i, fen := range fens {
+		wg.Add(1)
+		go func(i int, board *Board) {
+			defer wg.Done()
+			results[i] = NewEngine(DefaultTTMBSize, 1).Search(board, SearchLimits{Depth: 5})
+		}(i, InitFENBoard(fen))
+	}
+	wg.Wait()
+
+	for i := range fens {
+		if results[i].BestMove.enc != expected[i].BestMove.enc || results[i].Nodes != expected[i].Nodes {
+			t.Fatalf("%s: concurrent search differs\n\texpected: %s (%d nodes)\n\tgot: %s (%d nodes)",
+				fens[i], MoveToString(expected[i].BestMove), expected[i].Nodes, MoveToString(results[i].BestMove), results[i].Nodes)
+		}
+	}
+}
+
+func Test_SearchMultiPV(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	test := InitFENBoard("3r1k2/4npp1/1ppr3p/p6P/P2PPPP1/1NR5/5K2/2R5 w - - 0 1")
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	searchEngine.SetMultiPV(4)
+
+	result := searchEngine.Search(test, SearchLimits{Depth: 5})
+	if len(result.Lines) != 4 {
+		t.Fatalf("MultiPV 4 search returned %d lines", len(result.Lines))
+	}
+	if result.Lines[0].PV[0].enc != result.BestMove.enc {
+		t.Fatalf("First line %s does not start with the best move %s", MoveToString(result.Lines[0].PV[0]), MoveToString(result.BestMove))
+	}
+	rootMoves := make(map[string]bool)
+	for i, line := range result.Lines {
+		if i > 0 && line.Score > result.Lines[i-1].Score {
+			t.Fatalf("Line %d scores %d, above line %d at %d", i+1, line.Score, i, result.Lines[i-1].Score)
+		}
+		if rootMoves[MoveToString(line.PV[0])] {
+			t.Fatalf("Root move %s has more than one line", MoveToString(line.PV[0]))
+		}
+		rootMoves[MoveToString(line.PV[0])] = true
+		if _, ok := test.TryMoveUCI(MoveToString(line.PV[0])); !ok {
+			t.Fatalf("Line %d starts with an illegal move: %s", i+1, MoveToString(line.PV[0]))
+		}
+	}
+
+	// Asking for more lines than there are legal moves gives one line per move
+	test = InitFENBoard("8/8/8/8/8/5k2/8/7K w - - 0 1")
+	searchEngine.SetMultiPV(10)
+	result = searchEngine.Search(test, SearchLimits{Depth: 4})
+	if len(result.Lines) != 2 {
+		t.Fatalf("MultiPV 10 search with 2 legal moves returned %d lines", len(result.Lines))
+	}
+}
+
+func Test_SearchMoves(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	test := InitStartBoard()
+	a2a3, _ := test.TryMoveUCI("a2a3")
+	h2h3, _ := test.TryMoveUCI("h2h3")
+	searchEngine := NewEngine(DefaultTTMBSize, 2)
+	searchEngine.SetMultiPV(3)
+
+	// Only the listed moves get a line, even when more lines are asked for
+	result := searchEngine.Search(test, SearchLimits{Depth: 5, SearchMoves: []Move{a2a3, h2h3}})
+	if len(result.Lines) != 2 {
+		t.Fatalf("searchmoves a2a3 h2h3 with MultiPV 3 returned %d lines", len(result.Lines))
+	}
+	for _, line := range result.Lines {
+		if line.PV[0].enc != a2a3.enc && line.PV[0].enc != h2h3.enc {
+			t.Fatalf("searchmoves a2a3 h2h3 searched %s", MoveToString(line.PV[0]))
+		}
+	}
+	if result.BestMove.enc != result.Lines[0].PV[0].enc || result.Err != nil {
+		t.Fatalf("Best move %s is not the first line, error %v", MoveToString(result.BestMove), result.Err)
+	}
+
+	// An illegal entry is an error, even next to a legal one, the restriction is never dropped to search every move instead
+	e2e5 := NewMove(E2, E5, quietFlag)
+	for _, searchMoves := range [][]Move{{e2e5}, {a2a3, e2e5}} {
+		result = searchEngine.Search(test, SearchLimits{Depth: 5, SearchMoves: searchMoves})
+		if result.Err == nil || result.BestMove != NULL_MOVE || result.Nodes != 0 {
+			t.Fatalf("searchmoves with the illegal e2e5 played %s after %d nodes, error %v", MoveToString(result.BestMove), result.Nodes, result.Err)
+		}
+	}
+}
+
+func Test_SearchDeterministicNodeLimit(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	fen := "3r1k2/4npp1/1ppr3p/p6P/P2PPPP1/1NR5/5K2/2R5 w - - 0 1"
+	limits := SearchLimits{Nodes: 50000, Deterministic: true}
+
+	// A fresh engine, and an engine with more threads whose table is filled by an earlier search
+	expected := NewEngine(DefaultTTMBSize, 1).Search(InitFENBoard(fen), limits)
+	searchEngine := NewEngine(DefaultTTMBSize, 4)
+	searchEngine.Search(InitStartBoard(), SearchLimits{Depth: 5})
+	helperBoards := make([]*Board, 0, 3)
+	for _, helper := range searchEngine.threads[1:] {
+		helperBoards = append(helperBoards, helper.board)
+	}
+	result := searchEngine.Search(InitFENBoard(fen), limits)
+
+	// Helpers sit the deterministic search out, so they are not given a copy of the board either
+	for i, helper := range searchEngine.threads[1:] {
+		if helper.board != helperBoards[i] {
+			t.Fatalf("Helper %d got a board for a search it did not run", helper.id)
+		}
+	}
+
+	if expected.Nodes != limits.Nodes {
+		t.Fatalf("Node limited search searched %d nodes, wanted %d", expected.Nodes, limits.Nodes)
+	}
+	if result.Nodes != expected.Nodes || result.BestMove.enc != expected.BestMove.enc {
+		t.Fatalf("Deterministic searches differ\n\texpected: %s (%d nodes)\n\tgot: %s (%d nodes)",
+			MoveToString(expected.BestMove), expected.Nodes, MoveToString(result.BestMove), result.Nodes)
+	}
+	if len(result.Lines) != len(expected.Lines) || len(result.Lines[0].PV) != len(expected.Lines[0].PV) || result.Lines[0].Score != expected.Lines[0].Score {
+		t.Fatalf("Deterministic searches found different lines\n\texpected: %v\n\tgot: %v", expected.Lines, result.Lines)
+	}
+	for i, move := range expected.Lines[0].PV {
+		if result.Lines[0].PV[i].enc != move.enc {
+			t.Fatalf("Deterministic searches found different PVs at ply %d: %s vs %s", i, MoveToString(move), MoveToString(result.Lines[0].PV[i]))
+		}
+	}
+}
+
+func Test_SearchStoppedIteration(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	// Depth 5 plays g5h4, depth 6 finds d2e4 after searching g5h4 first
+	fen := "r1bqkb1r/4npp1/p1p4p/1p1pP1B1/8/1B6/PPPN1PPP/R2Q1RK1 w kq - 0 1"
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	expected := searchEngine.Search(InitFENBoard(fen), SearchLimits{Depth: 5, Deterministic: true})
+	deeper := searchEngine.Search(InitFENBoard(fen), SearchLimits{Depth: 6, Deterministic: true})
+	if expected.BestMove.enc == deeper.BestMove.enc {
+		t.Fatalf("Depth 5 and 6 both play %s", MoveToString(expected.BestMove))
+	}
+
+	// Stopped right after depth 6 started, no root move is searched in full and depth 5 stands
+	limits := SearchLimits{Nodes: expected.Nodes + 10, Deterministic: true}
+	result := searchEngine.Search(InitFENBoard(fen), limits)
+	if result.Nodes != limits.Nodes {
+		t.Fatalf("Node limited search searched %d nodes, wanted %d", result.Nodes, limits.Nodes)
+	}
+	if result.BestMove.enc != expected.BestMove.enc || result.PonderMove.enc != expected.PonderMove.enc {
+		t.Fatalf("Stopped search played %s %s, wanted %s %s", MoveToString(result.BestMove), MoveToString(result.PonderMove),
+			MoveToString(expected.BestMove), MoveToString(expected.PonderMove))
+	}
+	if len(result.Lines) != 1 || result.Lines[0].Depth != 5 || result.Lines[0].Score != expected.Lines[0].Score || len(result.Lines[0].PV) != len(expected.Lines[0].PV) {
+		t.Fatalf("Stopped search reported %v, wanted %v", result.Lines, expected.Lines)
+	}
+
+	// Stopped near the end of depth 6, the better move it has searched in full by then is played
+	result = searchEngine.Search(InitFENBoard(fen), SearchLimits{Nodes: deeper.Nodes - 100, Deterministic: true})
+	if result.BestMove.enc != deeper.BestMove.enc {
+		t.Fatalf("Stopped search played %s, wanted %s of the unfinished iteration", MoveToString(result.BestMove), MoveToString(deeper.BestMove))
+	}
+	line := result.Lines[0]
+	if line.Depth != 6 || line.Bound != ExactBound || line.PV[0].enc != result.BestMove.enc || result.PonderMove.enc != line.PV[1].enc {
+		t.Fatalf("Stopped search played %s %s but reported %v", MoveToString(result.BestMove), MoveToString(result.PonderMove), line)
+	}
+
+	// Stopped before the first iteration is done, there is still a legal move to play
+	board := InitFENBoard(fen)
+	result = searchEngine.Search(board, SearchLimits{Nodes: 1, Deterministic: true})
+	if _, ok := board.TryMoveUCI(MoveToString(result.BestMove)); !ok || len(result.Lines) != 0 {
+		t.Fatalf("Search stopped on the first node played %s with lines %v", MoveToString(result.BestMove), result.Lines)
+	}
+}
+
+func Test_SearchHardTimeLimit(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	// Nothing but the hard limit of the time manager ends this search, which would otherwise run for minutes.
+	// The engine is built before the clock starts, allocating the transposition table is not part of the search
+	searchEngine := NewEngine(DefaultTTMBSize, 2)
+	timeManager := timemanager.NewTimeManager(timemanager.Limits{MoveTime: 200})
+	timeManager.Start()
+	startTime := time.Now()
+	result := searchEngine.Search(InitStartBoard(), SearchLimits{TimeManager: timeManager})
+	// Twice the hard limit leaves room for slow builds such as -race
+	if elapsed := time.Since(startTime); elapsed > 2*timeManager.HardLimit() {
+		t.Fatalf("Search with a hard limit of %v took %v", timeManager.HardLimit(), elapsed)
+	}
+	if result.BestMove == NULL_MOVE || len(result.Lines) == 0 {
+		t.Fatalf("Search stopped by the hard limit found no move")
+	}
+}
+
+func Test_SearchObserver(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	test := InitFENBoard("r1bqkb1r/4npp1/p1p4p/1p1pP1B1/8/1B6/PPPN1PPP/R2Q1RK1 w kq - 0 1")
+	legalMoves := len(test.GenerateMoves(ALL, make([]Move, 0, MAX_MOVE_COUNT)))
+	searchEngine := NewEngine(DefaultTTMBSize, 2)
+	searchEngine.SetMultiPV(2)
+	observer := &testObserver{t: t}
+	result := searchEngine.Search(test, SearchLimits{Depth: 6, Observer: observer})
+
+	// The result is the best line of the last iteration, which is also the last line reported
+	if result.Depth != 6 || result.SelDepth < result.Depth || result.Bound != ExactBound || result.Time <= 0 {
+		t.Fatalf("Search to depth 6 returned depth %d seldepth %d bound %d after %v", result.Depth, result.SelDepth, result.Bound, result.Time)
+	}
+	// A PV cut short by a TT cutoff leaves the ponder move to the table
+	if result.PV[0].enc != result.BestMove.enc || (len(result.PV) > 1 && result.PV[1].enc != result.PonderMove.enc) ||
+		result.PonderMove == NULL_MOVE || result.Score != result.Lines[0].Score {
+		t.Fatalf("Result %s %s does not match its line %v", MoveToString(result.BestMove), MoveToString(result.PonderMove), result.PVLine)
+	}
+	lastLines := observer.iterations[len(observer.iterations)-2:]
+	if lastLines[0].MultiPV != 1 || lastLines[1].MultiPV != 2 || lastLines[0].Line.PV[0].enc != result.BestMove.enc || lastLines[1].Line.PV[0].enc != result.Lines[1].PV[0].enc {
+		t.Fatalf("Last lines reported %v, the search played %s from %v", lastLines, MoveToString(result.BestMove), result.Lines)
+	}
+	if lastLines[1].Nodes > result.Nodes || lastLines[1].Nodes == 0 {
+		t.Fatalf("Last line reported at %d nodes of %d", lastLines[1].Nodes, result.Nodes)
+	}
+
+	// Every line of depth 1 searches all of the root moves but the first moves of the lines before it, numbered in search order
+	var depth1Moves []RootMoveInfo
+	for _, rootMove := range observer.rootMoves {
+		if rootMove.Depth == 1 {
+			depth1Moves = append(depth1Moves, rootMove)
+		}
+	}
+	if len(depth1Moves) != 2*legalMoves-1 {
+		t.Fatalf("%d root moves reported at depth 1 for 2 lines of %d legal moves", len(depth1Moves), legalMoves)
+	}
+	for i, rootMove := range depth1Moves {
+		if wanted := i%legalMoves + 1; rootMove.MoveNumber != wanted {
+			t.Fatalf("Root move %s reported as number %d, wanted %d", MoveToString(rootMove.Move), rootMove.MoveNumber, wanted)
+		}
+	}
+	if observer.rootMoves[len(observer.rootMoves)-1].Depth != 6 {
+		t.Fatalf("Last root move reported at depth %d", observer.rootMoves[len(observer.rootMoves)-1].Depth)
+	}
+}
+
+func Test_SearchSilent(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputChannel := make(chan []byte)
+	go func() {
+		output, _ := io.ReadAll(reader)
+		outputChannel <- output
+	}()
+	stdout := os.Stdout
+	os.Stdout = writer
+	DebugMode = true
+	NewEngine(DefaultTTMBSize, 2).Search(InitStartBoard(), SearchLimits{Depth: 5})
+	DebugMode = false
+	os.Stdout = stdout
+	writer.Close()
+
+	// Without an observer the output is left to the caller
+	if output := <-outputChannel; len(output) != 0 {
+		t.Fatalf("Search without an observer printed:\n%s", output)
+	}
+}
+
+func Test_SearchMate(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	testCases := []struct {
+		fen      string
+		mate     int8
+		expected int
+	}{
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", 1, 1},
+		{"2k5/8/1K6/8/8/8/8/7R w - - 0 1", 4, 2}, // Stops at the shortest mate within the bound
+		{"2k5/8/1K6/8/8/8/8/7R w - - 0 1", 1, 0}, // No mate in 1
+		{StartingFen, 2, 0},
+	}
+
+	searchEngine := NewEngine(DefaultTTMBSize, 2)
+	for _, testCase := range testCases {
+		result := searchEngine.Search(InitFENBoard(testCase.fen), SearchLimits{Mate: testCase.mate})
+		if mateIn := result.Lines[0].MateIn(); mateIn != testCase.expected {
+			t.Fatalf("%s: go mate %d found mate in %d, wanted %d", testCase.fen, testCase.mate, mateIn, testCase.expected)
+		}
+	}
+}
+
+func Test_SearchHistoryCarriesOver(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	thread := searchEngine.threads[0]
+	searchEngine.Search(InitStartBoard(), SearchLimits{Depth: 6})
+
+	// What the next search should start from, the tables of the first one aged once
+	expected := &searchThread{history: thread.history, captureHistory: thread.captureHistory, counterMoves: thread.counterMoves,
+		counterMoveHistory: thread.counterMoveHistory, followUpHistory: thread.followUpHistory}
+	expected.ageHistory()
+	if expected.history == ([2][6][64]int16{}) || expected.counterMoveHistory == (continuationHistory{}) || expected.counterMoves == ([2][6][64]Move{}) {
+		t.Fatalf("Search to depth 6 left empty histories")
+	}
+
+	// Stopped on its first node, the second search has not updated anything yet
+	searchEngine.Search(InitStartBoard(), SearchLimits{Nodes: 1})
+	if thread.history != expected.history || thread.captureHistory != expected.captureHistory || thread.counterMoves != expected.counterMoves ||
+		thread.counterMoveHistory != expected.counterMoveHistory || thread.followUpHistory != expected.followUpHistory {
+		t.Fatalf("Second search did not start from the aged histories of the first")
+	}
+
+	searchEngine.ClearHistory()
+	if thread.history != ([2][6][64]int16{}) || thread.counterMoves != ([2][6][64]Move{}) || thread.followUpHistory != (continuationHistory{}) {
+		t.Fatalf("Histories left after ClearHistory")
+	}
+}
+
+func Test_SearchAgesHistoryOnce(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	searchEngine := NewEngine(DefaultTTMBSize, 1)
+	thread := searchEngine.threads[0]
+	// The white king cannot reach h8 within 6 plies, so no iteration updates this entry and only the aging changes it
+	thread.history[WHITE][KING][H8] = 1000
+	expected := &searchThread{}
+	expected.history[WHITE][KING][H8] = 1000
+	expected.ageHistory()
+
+	searchEngine.Search(InitStartBoard(), SearchLimits{Depth: 6})
+	if score := thread.history[WHITE][KING][H8]; score != expected.history[WHITE][KING][H8] {
+		t.Fatalf("History %d after a 6 iteration search, wanted %d from aging once", score, expected.history[WHITE][KING][H8])
+	}
+}
@@ -0,0 +1,111 @@
+package chessengine
+
+// Piece values used by the static exchange evaluation, indexed by piece type.
+// The king is worth more than everything else put together, so capturing into a defended square never pays off for it
+var seePieceValue = [6]int{100, 320, 330, 500, 900, 20000}
+
+/*
+Static Exchange Evaluation, https://www.chessprogramming.org/Static_Exchange_Evaluation
+
+Returns the material the side to move wins (or loses, when negative) if it plays move and both sides keep recapturing
+on the target square with their least valuable attacker, each side being free to stop once recapturing would lose.
+Sliders behind the pieces that already took part are discovered as they leave the square (x-rays).
+Pins and checks are ignored.
+
+Uses the swap algorithm, https://www.chessprogramming.org/SEE_-_The_Swap_Algorithm
+*/
+func (board *Board) SEE(move Move) int {
+	from := getStartingPosition(move)
+	to := getTargetPosition(move)
+	flag := GetFlag(move)
+
+	var gain [32]int
+	occupied := board.W.OccupancyBitBoard() | board.B.OccupancyBitBoard()
+
+	// Value of the piece standing on the target square once the move has been made
+	onSquare := seePieceValue[board.PieceInfoArr[from].pieceTYPE]
+	switch {
+	case flag == epCaptureFlag:
+		gain[0] = seePieceValue[PAWN]
+		// The captured pawn is not on the target square, but it may still hide a slider behind it
+		if board.GetTopState().TurnColor == WHITE {
+			occupied &^= BitBoard(1) << (to - 8)
+		} else {
+			occupied &^= BitBoard(1) << (to + 8)
+		}
+	case captureFlag&flag > 0:
+		gain[0] = seePieceValue[board.PieceInfoArr[to].pieceTYPE]
+	}
+	if flag&0b1000 > 0 { // Promotion, the pawn is exchanged for the promoted piece
+		promotedValue := seePieceValue[[4]int{KNIGHT, ROOK, BISHOP, QUEEN}[flag&0b11]]
+		gain[0] += promotedValue - seePieceValue[PAWN]
+		onSquare = promotedValue
+	}
+
+	occupied &^= BitBoard(1) << from
+	attackers := board.attackersTo(to, occupied) & occupied
+	rookLikes := board.W.Rook | board.W.Queen | board.B.Rook | board.B.Queen
+	bishopLikes := board.W.Bishop | board.W.Queen | board.B.Bishop | board.B.Queen
+
+	side := board.GetTopState().TurnColor ^ 1
+	depth := 0
+	for {
+		attackerBitBoard, attackerType := board.leastValuableAttacker(attackers, side)
+		if attackerBitBoard == 0 {
+			break
+		}
+
+		depth++
+		gain[depth] = onSquare - gain[depth-1] // Score of the recapturing side if the square is not taken back
+		if max(-gain[depth-1], gain[depth]) < 0 {
+			depth-- // This capture would only lose, so it is never made and cannot change the result
+			break
+		}
+		onSquare = seePieceValue[attackerType]
+
+		occupied &^= attackerBitBoard
+		// Only pawns, bishops, rooks and queens can uncover a slider behind them
+		if attackerType == PAWN || attackerType == BISHOP || attackerType == QUEEN {
+			attackers |= GetBishopMoves(to, BishopMask(to)&occupied) & bishopLikes
+		}
+		if attackerType == ROOK || attackerType == QUEEN {
+			attackers |= GetRookMoves(to, RookMask(to)&occupied) & rookLikes
+		}
+		attackers &= occupied
+		side ^= 1
+	}
+
+	for ; depth > 0; depth-- {
+		gain[depth-1] = -max(-gain[depth-1], gain[depth])
+	}
+	return gain[0]
+}
+
+// Returns every piece of either color attacking position, sliders are blocked by occupied
+func (board *Board) attackersTo(position Position, occupied BitBoard) BitBoard {
+	targetBitboard := BitBoard(1) << position
+
+	// Inverting by side columns to prevent going off the board, as in isAttacked
+	whitePawns := (Shift(targetBitboard, S+E) &^ Col1Full) | (Shift(targetBitboard, S+W) &^ Col8Full)
+	blackPawns := (Shift(targetBitboard, N+E) &^ Col1Full) | (Shift(targetBitboard, N+W) &^ Col8Full)
+
+	return whitePawns&board.W.Pawn | blackPawns&board.B.Pawn |
+		knightMoveBoard[position]&(board.W.Knight|board.B.Knight) |
+		kingMoveBoard[position]&(board.W.King|board.B.King) |
+		GetRookMoves(position, RookMask(position)&occupied)&(board.W.Rook|board.W.Queen|board.B.Rook|board.B.Queen) |
+		GetBishopMoves(position, BishopMask(position)&occupied)&(board.W.Bishop|board.W.Queen|board.B.Bishop|board.B.Queen)
+}
+
+// Returns the bitboard of a single least valuable piece of color among attackers, along with its type
+func (board *Board) leastValuableAttacker(attackers BitBoard, color int8) (BitBoard, int) {
+	pieces := &board.W
+	if color == BLACK {
+		pieces = &board.B
+	}
+	for pieceType, pieceBitBoard := range [6]BitBoard{pieces.Pawn, pieces.Knight, pieces.Bishop, pieces.Rook, pieces.Queen, pieces.King} {
+		if subset := attackers & pieceBitBoard; subset != 0 {
+			return subset & -subset, pieceType
+		}
+	}
+	return 0, NULL_PIECE
+}
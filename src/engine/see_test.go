@@ -0,0 +1,39 @@
+package chessengine
+
+import "testing"
+
+func Test_SEE(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		want int
+	}{
+		{"undefended pawn", "1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		{"knight for a pawn in a long exchange", "1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", 100 - 320},
+		{"queen for a pawn", "4k3/8/2p5/3p4/8/8/8/3QK3 w - - 0 1", "d1d5", 100 - 900},
+		{"x-ray rook behind the capturer", "3rk3/8/8/3p4/8/8/3R4/3RK3 w - - 0 1", "d2d5", 100},
+		{"x-ray rook behind the recapturer", "3rk3/3r4/8/3p4/8/8/8/3RK3 w - - 0 1", "d1d5", 100 - 500},
+		{"even trade", "4k3/2n5/8/3n4/8/4N3/8/4K3 w - - 0 1", "e3d5", 0},
+		{"bishop uncovers a queen", "4k3/8/8/3p4/4B3/8/8/Q3K3 w - - 0 1", "e4d5", 100},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		{"defended promotion", "1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", -100},
+		{"promotion capture", "1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", 500 + 900 - 100},
+		{"king cannot recapture a defended piece", "3rk3/3q4/8/8/8/8/3N4/4K3 b - - 0 1", "d7d2", 320},
+	}
+
+	for _, test := range tests {
+		board := InitFENBoard(test.fen)
+		move, ok := board.TryMoveUCI(test.move)
+		if !ok {
+			t.Fatalf("%s: %s is not legal in %s", test.name, test.move, test.fen)
+		}
+		if see := board.SEE(move); see != test.want {
+			t.Errorf("%s: SEE of %s was %d, wanted %d", test.name, test.move, see, test.want)
+		}
+	}
+}
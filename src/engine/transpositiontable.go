@@ -2,6 +2,7 @@ package chessengine
 
 import (
 	"fmt"
+	"sync/atomic"
 )
 
 const (
@@ -14,15 +15,50 @@ const (
 // TODO work on TT optimization, seems to function identical to single entry max-depth TT
 // july 2: switch to new version on v7f seems to break it???
 
+/*
+Every thread of an Engine writes the table without locks, so a subentry is two atomically written words
+and its key is stored xored with its data. A probe that reads the words of two different writes gets a key
+that matches nothing and the entry is ignored, https://www.chessprogramming.org/Shared_Hash_Table#Lock-less
+*/
 type ttSubEntry struct { // 16 bytes
-	zobristKey uint64 // 8 bytes
-	score      int    // 4 bytes
-	move       Move   // 2 bytes
-	turn       byte   // 1 byte
-	ttInfo     byte   // 1 byte
+	key  atomic.Uint64 // zobristKey ^ data
+	data atomic.Uint64 // score int32 | move 16 bits | turn 8 bits | ttInfo 8 bits
 }
 
-var NULLttSubEntry = ttSubEntry{}
+// Decoded copy of a subentry
+type ttData struct {
+	zobristKey uint64
+	score      int
+	move       Move
+	turn       byte
+	ttInfo     byte
+}
+
+func (data ttData) pack() uint64 {
+	return uint64(uint32(int32(data.score))) | uint64(data.move.enc)<<32 | uint64(data.turn)<<48 | uint64(data.ttInfo)<<56
+}
+
+func (subEntry *ttSubEntry) load() ttData {
+	data := subEntry.data.Load()
+	return ttData{
+		zobristKey: subEntry.key.Load() ^ data,
+		score:      int(int32(uint32(data))),
+		move:       Move{enc: uint16(data >> 32)},
+		turn:       byte(data >> 48),
+		ttInfo:     byte(data >> 56),
+	}
+}
+
+func (subEntry *ttSubEntry) store(data ttData) {
+	packed := data.pack()
+	subEntry.data.Store(packed)
+	subEntry.key.Store(data.zobristKey ^ packed)
+}
+
+func (subEntry *ttSubEntry) copyFrom(other *ttSubEntry) {
+	subEntry.data.Store(other.data.Load())
+	subEntry.key.Store(other.key.Load())
+}
 
 func getDepth(ttInfo byte) int8 {
 	return int8(ttInfo >> 2)
@@ -44,122 +80,193 @@ const ttEntry_ARcount = 3 // 2 always replace entries and one max depth
 const sizeTagHASHE = ttEntry_ARcount * 16
 const DefaultTTMBSize = 16
 
-var TableCapacity uint64 = (1024 * 1024 / sizeTagHASHE) * DefaultTTMBSize // 16 MB table with 72 byte entries
-var DebugTableSize = 0
-var DebugKeyCollisions = 0
-var DebugIndexCollisions = 0
-var DebugNewEntries = 0
-var DebugTableMinimumMoveCount uint16 = 0 // Marks the point at which an entry will never be usable, thus updating it increases Table size!
-var DebugTableHits = 0
-var DebugTableProbes = 0
+// TranspositionTable is shared by every search thread of an Engine, but never between engines
+type TranspositionTable struct {
+	hash_table    []ttEntry
+	TableCapacity uint64 // 16 MB table with 72 byte entries by default
 
-var hash_table []ttEntry
+	DebugTableSize             atomic.Int64 // Always counted as hashfull is reported from it
+	DebugKeyCollisions         atomic.Int64 // The other counters are only gathered in DebugMode
+	DebugIndexCollisions       atomic.Int64
+	DebugNewEntries            atomic.Int64
+	DebugTableMinimumMoveCount uint16 // Marks the point at which an entry will never be usable, thus updating it increases Table size!
+	DebugTableHits             atomic.Int64
+	DebugTableProbes           atomic.Int64
+}
 
-func init() {
-	TTReset(nil, DefaultTTMBSize)
+func NewTranspositionTable(sizeMB uint64) *TranspositionTable {
+	tt := &TranspositionTable{}
+	tt.TTReset(nil, sizeMB)
+	return tt
 }
 
-func getBestSubEntry(depth int8, turn byte, zobristKey uint64) (ttSubEntry, byte, Move) {
-	entry := &hash_table[zobristKey%TableCapacity]
+func (tt *TranspositionTable) getBestSubEntry(depth int8, turn byte, zobristKey uint64) (ttData, bool, byte, Move) {
+	entry := &tt.hash_table[zobristKey%tt.TableCapacity]
 	retvalMove := NULL_MOVE
 	retvalNodeType := NULLnode
 
 	for i := 0; i < ttEntry_ARcount; i++ {
-		if entry.subEntries[i].zobristKey == zobristKey {
+		subEntry := entry.subEntries[i].load()
+		if subEntry.zobristKey == zobristKey {
 			if retvalMove == NULL_MOVE {
-				retvalMove = entry.subEntries[i].move
-				retvalNodeType = getNodeType(entry.subEntries[i].ttInfo)
+				retvalMove = subEntry.move
+				retvalNodeType = getNodeType(subEntry.ttInfo)
 			}
-			if getDepth(entry.subEntries[i].ttInfo) >= depth &&
-				entry.subEntries[i].turn >= turn {
-				return entry.subEntries[i], retvalNodeType, retvalMove
+			if getDepth(subEntry.ttInfo) >= depth &&
+				subEntry.turn >= turn {
+				return subEntry, true, retvalNodeType, retvalMove
 			}
 		}
 	}
 
-	return NULLttSubEntry, retvalNodeType, retvalMove // nothing found that matches this zobristKey
+	return ttData{}, false, retvalNodeType, retvalMove // nothing found that matches this zobristKey
 }
 
-func getReplaceEntry(depth int8, turn byte, zobristKey uint64) *ttSubEntry {
-	entry := &hash_table[zobristKey%TableCapacity]
+func (tt *TranspositionTable) getReplaceEntry(depth int8, turn byte, zobristKey uint64) *ttSubEntry {
+	entry := &tt.hash_table[zobristKey%tt.TableCapacity]
 	var retval *ttSubEntry
 
-	if turn > entry.subEntries[0].turn { // Current entry is stale/empty, no need to shift
+	maxDepthEntry := entry.subEntries[0].load()
+	if turn > maxDepthEntry.turn { // Current entry is stale/empty, no need to shift
 		retval = &entry.subEntries[0]
-	} else if depth >= getDepth(entry.subEntries[0].ttInfo) { // Could replace max depth entry
+	} else if depth >= getDepth(maxDepthEntry.ttInfo) { // Could replace max depth entry
 		for i := 1; i < ttEntry_ARcount; i++ {
-			entry.subEntries[i] = entry.subEntries[i-1]
+			entry.subEntries[i].copyFrom(&entry.subEntries[i-1])
 		}
 		retval = &entry.subEntries[0]
 	} else { // If cannot replace max depth entry, shift all always-replaces entries right one, and push in new entry
 		for i := 2; i < ttEntry_ARcount; i++ {
-			entry.subEntries[i] = entry.subEntries[i-1]
+			entry.subEntries[i].copyFrom(&entry.subEntries[i-1])
 		}
 		retval = &entry.subEntries[1]
 	}
 
-	if turn > entry.subEntries[0].turn { // Updated a stale/empty entry, so TT now has a new valid entry
-		DebugTableSize++
-	} else if retval.zobristKey != zobristKey {
-		DebugKeyCollisions++
-	} else if retval.zobristKey == zobristKey {
-		DebugIndexCollisions++
+	if turn > maxDepthEntry.turn { // Updated a stale/empty entry, so TT now has a new valid entry
+		tt.DebugTableSize.Add(1)
+	} else if DebugMode {
+		if retval.load().zobristKey != zobristKey {
+			tt.DebugKeyCollisions.Add(1)
+		} else {
+			tt.DebugIndexCollisions.Add(1)
+		}
+	}
+	if DebugMode {
+		tt.DebugNewEntries.Add(1)
 	}
-	DebugNewEntries++
 
 	return retval
 }
 
-func probeHash(depth int8, turn byte, alpha, beta int, zobristKey uint64) (int, byte, Move) {
-	subEntry, entryNodeType, entryMove := getBestSubEntry(depth, turn, zobristKey)
-	DebugTableProbes++
+/*
+Mate scores count the plies from the root, but an entry may be probed at another ply than it was stored at
+through a transposition, so they are stored as the distance from the entry's own node instead,
+https://www.chessprogramming.org/Transposition_Table#Mate_Scores
+*/
+func scoreToTT(score int, plyFromRoot int8) int {
+	switch mate := scoreIsCheckmate(score); {
+	case mate > 0:
+		return score + int(plyFromRoot)
+	case mate < 0:
+		return score - int(plyFromRoot)
+	}
+	return score
+}
 
-	if subEntry != NULLttSubEntry {
-		DebugTableHits++
+// Turns a score stored by scoreToTT back into one counting the plies from the root of the probing search
+func scoreFromTT(score int, plyFromRoot int8) int {
+	switch mate := scoreIsCheckmate(score); {
+	case mate > 0:
+		return score - int(plyFromRoot)
+	case mate < 0:
+		return score + int(plyFromRoot)
+	}
+	return score
+}
+
+func (tt *TranspositionTable) probeHash(depth, plyFromRoot int8, turn byte, alpha, beta int, zobristKey uint64) (int, byte, Move) {
+	subEntry, found, entryNodeType, entryMove := tt.getBestSubEntry(depth, turn, zobristKey)
+	if DebugMode {
+		tt.DebugTableProbes.Add(1)
+	}
+
+	if found {
+		if DebugMode {
+			tt.DebugTableHits.Add(1)
+		}
 		nodeType := getNodeType(subEntry.ttInfo)
+		score := scoreFromTT(subEntry.score, plyFromRoot)
 		if nodeType == PVnode {
-			return subEntry.score, PVnode, subEntry.move
+			return score, PVnode, subEntry.move
 		}
-		if nodeType == ALLnode && subEntry.score <= alpha {
+		if nodeType == ALLnode && score <= alpha {
 			return alpha, ALLnode, NULL_MOVE
 		}
-		if nodeType == CUTnode && subEntry.score >= beta {
+		if nodeType == CUTnode && score >= beta {
 			return beta, CUTnode, NULL_MOVE
 		}
 	}
 	return MIN_VALUE, entryNodeType, entryMove
 }
 
-func recordHash(depth int8, nodeType, turn byte, score int, bestMove Move, zobristKey uint64) {
-	replacedSubEntry := getReplaceEntry(depth, turn, zobristKey)
+/*
+Returns the score, node type, depth and move of the deepest entry stored for zobristKey, whatever its depth.
+Unlike probeHash nothing is decided here, for callers that need to know how far an entry can be trusted, e.g. singular extensions
+*/
+func (tt *TranspositionTable) probeEntry(plyFromRoot int8, turn byte, zobristKey uint64) (score int, nodeType byte, depth int8, move Move, found bool) {
+	entry := &tt.hash_table[zobristKey%tt.TableCapacity]
+	depth = -1
+	for i := 0; i < ttEntry_ARcount; i++ {
+		subEntry := entry.subEntries[i].load()
+		if subEntry.zobristKey == zobristKey && subEntry.turn >= turn && getDepth(subEntry.ttInfo) > depth {
+			score, nodeType, depth, move, found = scoreFromTT(subEntry.score, plyFromRoot), getNodeType(subEntry.ttInfo), getDepth(subEntry.ttInfo), subEntry.move, true
+		}
+	}
+	return
+}
+
+func (tt *TranspositionTable) recordHash(depth, plyFromRoot int8, nodeType, turn byte, score int, bestMove Move, zobristKey uint64) {
+	tt.getReplaceEntry(depth, turn, zobristKey).store(ttData{
+		zobristKey: zobristKey,
+		score:      scoreToTT(score, plyFromRoot),
+		move:       bestMove,
+		turn:       turn,
+		ttInfo:     makeTTInfo(nodeType, depth),
+	})
+}
 
-	replacedSubEntry.zobristKey = zobristKey
-	replacedSubEntry.move = bestMove
-	replacedSubEntry.score = score
-	replacedSubEntry.ttInfo = makeTTInfo(nodeType, depth)
-	replacedSubEntry.turn = turn
+func (tt *TranspositionTable) TTReset(board *Board, sizeMB uint64) {
+	tt.TableCapacity = (1024 * 1024 / sizeTagHASHE) * sizeMB
+	tt.hash_table = make([]ttEntry, tt.TableCapacity)
+	tt.DebugTableSize.Store(0)
+	tt.TTDebugReset(board)
 }
 
-func TTReset(board *Board, sizeMB uint64) {
-	TableCapacity = (1024 * 1024 / sizeTagHASHE) * sizeMB
-	hash_table = make([]ttEntry, TableCapacity)
-	DebugTableSize = 0
-	TTDebugReset(board)
+// Empties the table while keeping its size
+func (tt *TranspositionTable) clear() {
+	clear(tt.hash_table)
+	tt.DebugTableSize.Store(0)
 }
 
-func TTDebugReset(board *Board) {
-	DebugKeyCollisions = 0
-	DebugIndexCollisions = 0
-	DebugNewEntries = 0
-	DebugTableHits = 0
-	DebugTableProbes = 0
+func (tt *TranspositionTable) TTDebugReset(board *Board) {
+	tt.DebugKeyCollisions.Store(0)
+	tt.DebugIndexCollisions.Store(0)
+	tt.DebugNewEntries.Store(0)
+	tt.DebugTableHits.Store(0)
+	tt.DebugTableProbes.Store(0)
 	if board == nil {
-		DebugTableMinimumMoveCount = 0
+		tt.DebugTableMinimumMoveCount = 0
 	} else {
-		DebugTableMinimumMoveCount = board.moveCount() - 1
+		tt.DebugTableMinimumMoveCount = board.moveCount() - 1
 	}
 }
 
-func TTDebugInfo() string {
-	return fmt.Sprintf("TT occupancy: %0.2f%%\n\tNew Entries: %d(%0.2f%%)\n\tKey Collisions: %d(%0.2f%%)\n\tIndex Collisions: %d(%0.2f%%)\n\tHit Rate: %0.2f%%\n", 100*float32(DebugTableSize)/float32(TableCapacity), DebugNewEntries, 100*float32(DebugNewEntries)/float32(ttEntry_ARcount*DebugTableSize), DebugKeyCollisions, 100*float32(DebugKeyCollisions)/float32(DebugNewEntries), DebugIndexCollisions, 100*float32(DebugIndexCollisions)/float32(DebugNewEntries), 100*float32(DebugTableHits)/float32(DebugTableProbes))
+// Permille of the table that holds entries, as reported by UCI "hashfull"
+func (tt *TranspositionTable) hashFull() int {
+	return int(float64(tt.DebugTableSize.Load()) / float64(tt.TableCapacity) * 1000)
+}
+
+func (tt *TranspositionTable) TTDebugInfo() string {
+	tableSize, newEntries := tt.DebugTableSize.Load(), tt.DebugNewEntries.Load()
+	keyCollisions, indexCollisions := tt.DebugKeyCollisions.Load(), tt.DebugIndexCollisions.Load()
+	return fmt.Sprintf("TT occupancy: %0.2f%%\n\tNew Entries: %d(%0.2f%%)\n\tKey Collisions: %d(%0.2f%%)\n\tIndex Collisions: %d(%0.2f%%)\n\tHit Rate: %0.2f%%\n", 100*float32(tableSize)/float32(tt.TableCapacity), newEntries, 100*float32(newEntries)/float32(ttEntry_ARcount*tableSize), keyCollisions, 100*float32(keyCollisions)/float32(newEntries), indexCollisions, 100*float32(indexCollisions)/float32(newEntries), 100*float32(tt.DebugTableHits.Load())/float32(tt.DebugTableProbes.Load()))
 }
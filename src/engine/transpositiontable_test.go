@@ -0,0 +1,95 @@
+package chessengine
+
+import (
+	"testing"
+)
+
+func Test_TTMateScores(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	var key uint64 = 0x9d39247e33776d41
+
+	testCases := []struct {
+		score       int
+		storedPly   int8
+		probedPly   int8
+		expectedPly int
+	}{
+		{-MATE_SCORE - 9, 6, 2, 5},  // Mating 3 plies after the node
+		{MATE_SCORE + 8, 6, 3, 5},   // Mated 2 plies after the node
+		{-MATE_SCORE - 1, 0, 7, 8},  // Mate in 1 at the root, found again deeper in the tree
+		{MATE_SCORE + 10, 10, 0, 0}, // Mated right at the node
+	}
+	for _, testCase := range testCases {
+		tt.recordHash(4, testCase.storedPly, PVnode, 1, testCase.score, NULL_MOVE, key)
+		score, _, _ := tt.probeHash(4, testCase.probedPly, 1, MIN_VALUE, MAX_VALUE, key)
+
+		expected := -MATE_SCORE - testCase.expectedPly
+		if testCase.score < 0 {
+			expected = MATE_SCORE + testCase.expectedPly
+		}
+		if score != expected {
+			t.Fatalf("Mate score %d stored at ply %d probed at ply %d as %d, wanted %d",
+				testCase.score, testCase.storedPly, testCase.probedPly, score, expected)
+		}
+	}
+
+	// Other scores are stored as they are
+	tt.recordHash(4, 6, PVnode, 1, 250, NULL_MOVE, key)
+	if score, _, _ := tt.probeHash(4, 2, 1, MIN_VALUE, MAX_VALUE, key); score != 250 {
+		t.Fatalf("Score 250 probed as %d", score)
+	}
+}
+
+func Test_TTTornEntry(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	var key uint64 = 0x9d39247e33776d41
+	move := Move{enc: 0x1234}
+
+	tt.recordHash(4, 0, PVnode, 1, 250, move, key)
+	if score, nodeType, ttMove := tt.probeHash(4, 0, 1, MIN_VALUE, MAX_VALUE, key); score != 250 || nodeType != PVnode || ttMove != move {
+		t.Fatalf("Entry probed as score %d node type %d move %x", score, nodeType, ttMove.enc)
+	}
+
+	// Another thread overwriting only the data word must not leave an entry that reads as valid
+	subEntry := &tt.hash_table[key%tt.TableCapacity].subEntries[0]
+	subEntry.data.Store(ttData{zobristKey: key ^ 1, score: -300, move: Move{enc: 0x4321}, turn: 1, ttInfo: makeTTInfo(PVnode, 9)}.pack())
+	if score, nodeType, ttMove := tt.probeHash(4, 0, 1, MIN_VALUE, MAX_VALUE, key); score != MIN_VALUE || nodeType != NULLnode || ttMove != NULL_MOVE {
+		t.Fatalf("Torn entry probed as score %d node type %d move %x", score, nodeType, ttMove.enc)
+	}
+	if _, _, _, _, found := tt.probeEntry(0, 1, key); found {
+		t.Fatalf("Torn entry found by probeEntry")
+	}
+}
+
+func Test_TTMateTransposition(t *testing.T) {
+	InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	InitZobristTable()
+	InitPeSTO()
+
+	testCases := []struct {
+		fen  string
+		mate int
+	}{
+		{"2k5/8/1K6/8/8/8/8/7R w - - 0 1", 2},
+		{"4k3/8/8/4K3/8/8/8/7R w - - 0 1", 3},
+		{"k7/8/2K5/8/8/8/8/7Q w - - 0 1", 2},
+		{"7r/8/8/8/8/1k6/8/2K5 b - - 0 1", 2},
+	}
+	for _, testCase := range testCases {
+		// The second search starts one ply further and is too shallow to see the mate on its own,
+		// so it has to find it in the entries the first one left in the table
+		searchEngine := NewEngine(DefaultTTMBSize, 1)
+		board := InitFENBoard(testCase.fen)
+		result := searchEngine.Search(board, SearchLimits{Depth: 12})
+		if mateIn := result.Lines[0].MateIn(); mateIn != testCase.mate {
+			t.Fatalf("%s: found mate in %d, wanted %d", testCase.fen, mateIn, testCase.mate)
+		}
+
+		board.MakeMove(result.BestMove)
+		result = searchEngine.Search(board, SearchLimits{Depth: 3})
+		// The side to move is mated after its remaining moves
+		if mateIn := result.Lines[0].MateIn(); mateIn != -(testCase.mate - 1) {
+			t.Fatalf("%s after %s: found mate in %d, wanted %d", testCase.fen, MoveToString(board.GetTopState().PrecedentMove), mateIn, -(testCase.mate - 1))
+		}
+	}
+}
@@ -35,5 +35,6 @@ func InitZobristTable() {
 	}
 
 	zobristWhiteSideToMove = ranval(&x)
+	initCuckoo()
 	initZobrist = true
 }
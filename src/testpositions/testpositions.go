@@ -51,17 +51,19 @@ type BKTest struct {
 // 	{"2q1rr1k/3bbnnp/p2p1pp1/2pPp3/PpP1P1P1/1P2BNNP/2BQ1PRK/7R b - - 0 1", engine.Move{engine.f5, engine.NoSquare, engine.Pawn, engine.NoPiece, engine.NoPiece}},
 
 // Returns # of tests passed
-func Run(timePerCase int, hashSize uint64) (totalCorrect, totalRun int) {
+func Run(searchEngine *engine.Engine, timePerCase int, hashSize uint64) (totalCorrect, totalRun int) {
 	testCases := GetTests()
 	for _, testCase := range testCases {
 		board := engine.InitFENBoard(testCase.fen)
-		engine.TTReset(board, hashSize)
+		searchEngine.TTReset(board, hashSize)
+		searchEngine.ClearHistory()
 		searchCancelChannel := make(chan struct{})
 		go func() {
 			time.Sleep(time.Duration(timePerCase) * time.Millisecond)
 			close(searchCancelChannel)
 		}()
-		out := engine.MoveToString(board.StartSearchNoDepth(time.Now(), searchCancelChannel))
+		result := searchEngine.Search(board, engine.SearchLimits{CancelChannel: searchCancelChannel})
+		out := engine.MoveToString(result.BestMove)
 
 		passed := false
 
@@ -71,29 +73,32 @@ func Run(timePerCase int, hashSize uint64) (totalCorrect, totalRun int) {
 				break
 			}
 		}
+		searched := fmt.Sprintf("depth %d seldepth %d score %s nodes %d", result.Depth, result.SelDepth, result.ScoreString(), result.Nodes)
 		if passed {
-			fmt.Printf("%s PASSED\n", testCase.id)
+			fmt.Printf("%s PASSED: %s, %s\n", testCase.id, out, searched)
 			totalCorrect++
 		} else {
-			fmt.Printf("%s FAILED: wanted %v, got %s\n", testCase.id, testCase.bestMoves, out)
+			fmt.Printf("%s FAILED: wanted %v, got %s, %s\n", testCase.id, testCase.bestMoves, out, searched)
 		}
 	}
 	return totalCorrect, len(testCases)
 }
 
-func Bench(depthPerCase int8, hashSize uint64) (avgNodes float64, avgTime float64) {
+func Bench(searchEngine *engine.Engine, depthPerCase int8, hashSize uint64) (avgNodes float64, avgTime float64, nps float64) {
 	testCases := GetTests()
 	var totalNodeCount uint64
-	var totalTime int64
+	var totalTime time.Duration
 	for _, testCase := range testCases {
 		board := engine.InitFENBoard(testCase.fen)
-		engine.TTReset(board, hashSize)
-		searchCancelChannel := make(chan struct{})
-		_, nodes, time := board.StartSearchDepth(time.Now(), depthPerCase, searchCancelChannel)
+		searchEngine.TTReset(board, hashSize)
+		searchEngine.ClearHistory()
+		startTime := time.Now()
+		nodes := searchEngine.Search(board, engine.SearchLimits{StartTime: startTime, Depth: depthPerCase}).Nodes
 		totalNodeCount += nodes
-		totalTime += time
+		totalTime += time.Since(startTime)
 	}
-	return float64(totalNodeCount) / float64(len(testCases)), float64(totalTime) / float64(len(testCases))
+	avgTime = float64(totalTime.Milliseconds()) / float64(len(testCases))
+	return float64(totalNodeCount) / float64(len(testCases)), avgTime, float64(totalNodeCount) / totalTime.Seconds()
 }
 
 func GetTests() (retval []BKTest) {
@@ -0,0 +1,156 @@
+package chessengine
+
+// Path: src/timemanager/timemanager.go
+// Decides how long a search on the clock may think, https://www.chessprogramming.org/Time_Management
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMoveOverhead = 30 // ms, default of the UCI "Move Overhead" option
+
+	maxMovesToGo        = 50  // movestogo beyond this is treated like sudden death
+	hardLimitFactor     = 4   // How many soft limits the hard limit may grow to
+	minHardLimitShare   = 0.2 // Share of the remaining time the hard limit may always use
+	maxHardLimitShare   = 0.9 // Share of the remaining time the hard limit never exceeds, even on the last move before the time control
+	incrementShare      = 0.75
+	iterationGrowth     = 2   // An iteration takes about as long as all earlier ones together
+	instabilityBonus    = 0.4 // Soft limit extension per best move change in the last iterations
+	scoreDropMargin     = 25  // Score drop in cp between iterations that counts as falling behind
+	scoreDropBonus      = 0.5 // Soft limit extension when the score dropped
+	dominantIterations  = 6   // Iterations the best move has to survive unchanged to count as dominant
+	dominantScale       = 0.5 // Soft limit scale once the best move is dominant
+	instabilityDecay    = 0.5 // Weight kept of the older best move changes every iteration
+	minimumSearchTimeMs = 1   // The search is always given at least this much time
+)
+
+// Clock state sent with the UCI "go" command, all times in milliseconds
+type Limits struct {
+	Time         int64 // Time left on the clock of the side to move
+	Increment    int64 // Increment per move of the side to move
+	MovesToGo    int64 // Moves until the next time control, 0 = sudden death
+	MoveTime     int64 // Fixed time for this move, overrides the clock when > 0
+	MoveOverhead int64 // Time lost per move to communication with the GUI
+	GamePhase    int   // 24 = opening, 0 = bare kings, used to guess how many moves are left in sudden death
+}
+
+/*
+TimeManager computes a soft and a hard limit for a single search:
+  - the hard limit is a deadline the search polls for and stops at wherever it is, it always leaves the move overhead on the clock
+  - the soft limit is checked after every iteration, it is extended while the best move is unstable or the score drops,
+    and shrunk once one move has dominated for several iterations
+*/
+type TimeManager struct {
+	softLimit time.Duration
+	hardLimit time.Duration
+	fixedTime bool // movetime searches use their whole time, no matter how the search goes
+
+	started atomic.Int64 // time.Since(clockOrigin) at Start plus one, 0 while the clock has not been started
+
+	iterations      int
+	prevBestMove    string
+	prevScore       int
+	stableCount     int     // Iterations in a row the best move has not changed
+	bestMoveChanges float64 // Decaying count of recent best move changes
+}
+
+// Computes the limits for the side to move, the clock only starts running with Start
+func NewTimeManager(limits Limits) *TimeManager {
+	tm := &TimeManager{}
+	overhead := max(0, limits.MoveOverhead)
+
+	if limits.MoveTime > 0 {
+		tm.fixedTime = true
+		tm.softLimit = milliseconds(max(minimumSearchTimeMs, limits.MoveTime-overhead))
+		tm.hardLimit = tm.softLimit
+		return tm
+	}
+
+	available := max(minimumSearchTimeMs, limits.Time-overhead)
+	movesToGo := limits.MovesToGo
+	if movesToGo <= 0 || movesToGo > maxMovesToGo {
+		// Sudden death, expect fewer moves to be left as the material comes off the board
+		movesToGo = int64(30 + (30*limits.GamePhase)/24)
+	}
+
+	soft := float64(available)/float64(movesToGo) + incrementShare*float64(limits.Increment)
+	// Never plan on more than a share of the clock, the increment only arrives after the move.
+	// The share grows as the time control comes closer, up to nearly all of it on the last move
+	hardLimitShare := min(maxHardLimitShare, max(minHardLimitShare, 1.5/float64(movesToGo)))
+	hard := min(hardLimitFactor*soft, hardLimitShare*float64(available))
+	soft = min(soft, hard)
+
+	tm.softLimit = milliseconds(max(minimumSearchTimeMs, int64(soft)))
+	tm.hardLimit = milliseconds(max(minimumSearchTimeMs, int64(hard)))
+	return tm
+}
+
+// Monotonic reference the start of the clock is stored against, so the search threads can read it without a lock
+var clockOrigin = time.Now()
+
+func milliseconds(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (tm *TimeManager) SoftLimit() time.Duration {
+	return tm.softLimit
+}
+
+func (tm *TimeManager) HardLimit() time.Duration {
+	return tm.hardLimit
+}
+
+// Starts the clock, a ponder search only starts it on ponderhit
+func (tm *TimeManager) Start() {
+	tm.started.Store(int64(time.Since(clockOrigin)) + 1)
+}
+
+// Elapsed time since Start, 0 while the clock has not been started
+func (tm *TimeManager) Elapsed() time.Duration {
+	started := tm.started.Load()
+	if started == 0 {
+		return 0
+	}
+	return time.Since(clockOrigin) - time.Duration(started-1)
+}
+
+// Whether the clock has run past the hard limit, polled by the search every few thousand nodes
+func (tm *TimeManager) HardLimitReached() bool {
+	return tm.Elapsed() >= tm.hardLimit
+}
+
+/*
+Called after every completed iteration with its best move and score,
+returns true when the next iteration is not worth starting
+*/
+func (tm *TimeManager) IterationDone(bestMove string, score int) bool {
+	tm.iterations++
+	if tm.iterations > 1 && bestMove != tm.prevBestMove {
+		tm.bestMoveChanges++
+		tm.stableCount = 0
+	} else {
+		tm.stableCount++
+	}
+	scoreDropped := tm.iterations > 1 && score < tm.prevScore-scoreDropMargin
+	tm.prevBestMove = bestMove
+	tm.prevScore = score
+
+	elapsed := tm.Elapsed()
+	if elapsed == 0 || tm.fixedTime {
+		return false // Pondering, or a fixed movetime that is ended by the hard limit only
+	}
+
+	scale := 1 + instabilityBonus*tm.bestMoveChanges
+	if scoreDropped {
+		scale += scoreDropBonus
+	}
+	if tm.stableCount >= dominantIterations && !scoreDropped {
+		scale *= dominantScale
+	}
+	tm.bestMoveChanges *= instabilityDecay
+
+	// The next iteration is only started if it can be expected to finish before the soft limit
+	return iterationGrowth*elapsed >= time.Duration(scale*float64(tm.softLimit))
+}
@@ -0,0 +1,95 @@
+package chessengine
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_LimitsNeverFlag(t *testing.T) {
+	testCases := []Limits{
+		{Time: 60000},
+		{Time: 10000, Increment: 1000},
+		{Time: 300},
+		{Time: 50, Increment: 2000}, // Increment far above the clock
+		{Time: 1000, MovesToGo: 1},
+		{Time: 5000, MovesToGo: 2, Increment: 100},
+		{Time: 20, MoveOverhead: 30},
+	}
+	for _, limits := range testCases {
+		limits.MoveOverhead = max(limits.MoveOverhead, DefaultMoveOverhead)
+		limits.GamePhase = 24
+		tm := NewTimeManager(limits)
+		clock := time.Duration(limits.Time-limits.MoveOverhead) * time.Millisecond
+		if tm.HardLimit() > max(clock, time.Millisecond) {
+			t.Fatalf("%+v: hard limit %v beyond the %v left after the move overhead", limits, tm.HardLimit(), clock)
+		}
+		if tm.SoftLimit() > tm.HardLimit() || tm.SoftLimit() <= 0 {
+			t.Fatalf("%+v: soft limit %v outside of (0, %v]", limits, tm.SoftLimit(), tm.HardLimit())
+		}
+	}
+
+	tm := NewTimeManager(Limits{MoveTime: 500, MoveOverhead: DefaultMoveOverhead})
+	if tm.SoftLimit() != tm.HardLimit() || tm.HardLimit() != (500-DefaultMoveOverhead)*time.Millisecond {
+		t.Fatalf("movetime 500: soft %v hard %v", tm.SoftLimit(), tm.HardLimit())
+	}
+
+	// The increment is spent along with the clock
+	if NewTimeManager(Limits{Time: 10000, Increment: 1000}).SoftLimit() <= NewTimeManager(Limits{Time: 10000}).SoftLimit() {
+		t.Fatalf("The increment did not add to the soft limit")
+	}
+}
+
+func Test_IterationDone(t *testing.T) {
+	// Clock started just long enough ago for an iteration without any adjustment to stop the search
+	started := func() *TimeManager {
+		tm := NewTimeManager(Limits{Time: 60000})
+		tm.started.Store(int64(time.Since(clockOrigin) - tm.SoftLimit()/iterationGrowth))
+		return tm
+	}
+
+	// An unstable best move earns more time
+	tm := started()
+	tm.IterationDone("e2e4", 20)
+	if tm.IterationDone("d2d4", 20) {
+		t.Fatalf("Stopped while the best move was changing")
+	}
+
+	// A score drop earns more time
+	tm = started()
+	tm.IterationDone("e2e4", 20)
+	if tm.IterationDone("e2e4", -40) {
+		t.Fatalf("Stopped while the score was dropping")
+	}
+
+	// A best move that dominates stops the search early
+	tm = started()
+	tm.started.Store(int64(time.Since(clockOrigin) - tm.SoftLimit()/(2*iterationGrowth)))
+	stopped := false
+	for i := 0; i < dominantIterations && !stopped; i++ {
+		stopped = tm.IterationDone("e2e4", 20)
+	}
+	if !stopped {
+		t.Fatalf("Did not stop early with a dominant best move")
+	}
+
+	// Pondering, the clock has not been started
+	tm = NewTimeManager(Limits{Time: 1})
+	if tm.IterationDone("e2e4", 20) {
+		t.Fatalf("Stopped before the clock was started")
+	}
+}
+
+func Test_HardLimitReached(t *testing.T) {
+	tm := NewTimeManager(Limits{MoveTime: 100})
+	if tm.HardLimitReached() {
+		t.Fatalf("Hard limit reached before the clock was started")
+	}
+	tm.Start()
+	if tm.HardLimitReached() {
+		t.Fatalf("Hard limit reached right after the clock was started")
+	}
+	tm.started.Store(int64(time.Since(clockOrigin) - tm.HardLimit()))
+	if !tm.HardLimitReached() {
+		t.Fatalf("Hard limit not reached after %v", tm.Elapsed())
+	}
+}
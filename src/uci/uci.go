@@ -7,10 +7,13 @@ import (
 	"bufio"
 	engine "chessengine/src/engine"
 	testpositions "chessengine/src/testpositions"
+	timemanager "chessengine/src/timemanager"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,14 +21,70 @@ const (
 	name = "ChessEngineEmre v13a (testmvv.py BLIND, added go perft)"
 )
 
-var options Options = Options{Hash: engine.DefaultTTMBSize, OwnBook: false}
+var options Options = Options{Hash: engine.DefaultTTMBSize, OwnBook: false, Threads: 1, MultiPV: 1, Deterministic: false, Ponder: false, MoveOverhead: timemanager.DefaultMoveOverhead, PruningMargins: engine.DefaultPruningMargins, InternalIterative: engine.InternalIterativeReductions}
 var uciDebug bool = false
 var gameBoard *engine.Board
+var searchEngine *engine.Engine = engine.NewEngine(options.Hash, options.Threads)
 var searchCancelChannel chan struct{} = make(chan struct{})
+var searchPonderhitChannel chan struct{} = nil // Closed by ponderhit while a go ponder search is running
+var searchCancelMutex sync.Mutex
+
+// Keywords that can follow go, used to find where the searchmoves list ends
+var goParameters = map[string]bool{
+	"searchmoves": true, "ponder": true, "wtime": true, "btime": true, "winc": true, "binc": true,
+	"movestogo": true, "depth": true, "nodes": true, "mate": true, "movetime": true, "infinite": true,
+}
 
 type Options struct {
-	OwnBook bool   // Set engine [true/false] to pull from openingbook.txt, default false
-	Hash    uint64 // in MB, default 16, min 1, max 1024
+	OwnBook       bool   // Set engine [true/false] to pull from openingbook.txt, default false
+	Hash          uint64 // in MB, default 16, min 1, max 1024
+	Threads       int    // Number of Lazy SMP search threads, default 1, min 1, max 128
+	MultiPV       int    // Number of best lines searched and reported, default 1, min 1, max 256
+	Deterministic bool   // Set engine [true/false] to ignore the clock, search single threaded on a cleared hash and play the first book move, default false
+	Ponder        bool   // Set by the GUI [true/false] when it will send go ponder, default false
+	MoveOverhead  int64  // in ms, time kept back per move for GUI and network lag, default 30, min 0, max 5000
+
+	// in cp per ply, margins of the shallow depth pruning exposed for tuning as ReverseFutilityMargin, FutilityMargin and RazoringMargin, min 0, max 1000
+	PruningMargins engine.PruningMargins
+	// How nodes without a TT move are searched, set as InternalIterative [Off/IIR], default IIR
+	InternalIterative engine.InternalIterativeMode
+}
+
+// Values of the InternalIterative option, in the order of engine.InternalIterativeMode
+var internalIterativeNames = []string{"Off", "IIR"}
+
+// Root moves are only announced once a search has run this long, short searches would flood the GUI with them
+const currMoveDelay = 3 * time.Second
+
+// Prints the progress of a search as UCI info lines
+type infoPrinter struct{}
+
+func (infoPrinter) Iteration(info engine.IterationInfo) {
+	fmt.Println(infoString(info))
+	if info.DebugInfo != "" {
+		fmt.Print(info.DebugInfo)
+	}
+}
+
+func (infoPrinter) RootMove(info engine.RootMoveInfo) {
+	if info.Time >= currMoveDelay {
+		fmt.Printf("info depth %d currmove %s currmovenumber %d\n", info.Depth, engine.MoveToString(info.Move), info.MoveNumber)
+	}
+}
+
+/*
+Formats a single MultiPV line as:
+
+info depth <depth> seldepth <maxdepth searched> multipv <line number> score <cp/mate> [lowerbound/upperbound] nodes <nodecount of all threads> nps <nodes / time> hashfull <permille> time <time taken in ms> pv <pv>
+*/
+func infoString(info engine.IterationInfo) string {
+	nps := int64(float64(info.Nodes) / info.Time.Seconds())
+	pv := make([]string, len(info.Line.PV))
+	for i, move := range info.Line.PV {
+		pv[i] = engine.MoveToString(move)
+	}
+	return fmt.Sprintf("info depth %d seldepth %d multipv %d score %s nodes %d nps %d hashfull %d time %d pv %s",
+		info.Line.Depth, info.Line.SelDepth, info.MultiPV, info.Line.ScoreString(), info.Nodes, nps, info.HashFull, info.Time.Milliseconds(), strings.Join(pv, " "))
 }
 
 // UCI is the main function to start the UCI loop
@@ -63,11 +122,17 @@ func readCommand(reader *bufio.Reader) (bool, error) {
 		if gameBoard == nil {
 			return true, fmt.Errorf("invalid board")
 		}
-		go commandGo(text)
+		go func() {
+			if _, err := commandGo(text); err != nil {
+				fmt.Printf("info string %v\n", err) // Anything else is not UCI while the GUI waits for a bestmove
+			}
+		}()
 		return true, nil
 	} else if strings.HasPrefix(text, "stop") {
-		close(searchCancelChannel)
+		stopSearch(searchCancelChannel)
 		return true, nil
+	} else if strings.HasPrefix(text, "ponderhit") {
+		return true, commandPonderHit()
 	} else if strings.HasPrefix(text, "ucinewgame") {
 		commandUCINewGame()
 		return true, nil
@@ -109,7 +174,7 @@ func commandOptions(text string) error {
 		text = strings.TrimPrefix(text, "name Hash value ")
 		hashSize, _ := strconv.Atoi(text)
 		options.Hash = uint64(hashSize)
-		engine.TTReset(gameBoard, uint64(options.Hash))
+		searchEngine.TTReset(gameBoard, uint64(options.Hash))
 	} else if strings.HasPrefix(text, "name OwnBook value ") {
 		text = strings.TrimPrefix(text, "name OwnBook value ")
 		switch text {
@@ -120,14 +185,82 @@ func commandOptions(text string) error {
 		default:
 			return fmt.Errorf("unvalid OwnBook option, wanted: [true/false], got: %s", text)
 		}
+	} else if strings.HasPrefix(text, "name Threads value ") {
+		text = strings.TrimPrefix(text, "name Threads value ")
+		threads, err := strconv.Atoi(text)
+		if err != nil || threads < 1 || threads > 128 {
+			return fmt.Errorf("invalid Threads option, wanted: [1-128], got: %s", text)
+		}
+		options.Threads = threads
+		searchEngine.SetThreadCount(threads)
+	} else if strings.HasPrefix(text, "name MultiPV value ") {
+		text = strings.TrimPrefix(text, "name MultiPV value ")
+		multiPV, err := strconv.Atoi(text)
+		if err != nil || multiPV < 1 || multiPV > 256 {
+			return fmt.Errorf("invalid MultiPV option, wanted: [1-256], got: %s", text)
+		}
+		options.MultiPV = multiPV
+		searchEngine.SetMultiPV(multiPV)
+	} else if strings.HasPrefix(text, "name Deterministic value ") {
+		text = strings.TrimPrefix(text, "name Deterministic value ")
+		switch text {
+		case "true":
+			options.Deterministic = true
+		case "false":
+			options.Deterministic = false
+		default:
+			return fmt.Errorf("unvalid Deterministic option, wanted: [true/false], got: %s", text)
+		}
+	} else if strings.HasPrefix(text, "name Ponder value ") {
+		text = strings.TrimPrefix(text, "name Ponder value ")
+		switch text {
+		case "true":
+			options.Ponder = true
+		case "false":
+			options.Ponder = false
+		default:
+			return fmt.Errorf("unvalid Ponder option, wanted: [true/false], got: %s", text)
+		}
+	} else if strings.HasPrefix(text, "name Move Overhead value ") {
+		text = strings.TrimPrefix(text, "name Move Overhead value ")
+		moveOverhead, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || moveOverhead < 0 || moveOverhead > 5000 {
+			return fmt.Errorf("invalid Move Overhead option, wanted: [0-5000], got: %s", text)
+		}
+		options.MoveOverhead = moveOverhead
+	} else if strings.HasPrefix(text, "name ReverseFutilityMargin value ") {
+		return setPruningMargin(strings.TrimPrefix(text, "name ReverseFutilityMargin value "), "ReverseFutilityMargin", &options.PruningMargins.ReverseFutility)
+	} else if strings.HasPrefix(text, "name FutilityMargin value ") {
+		return setPruningMargin(strings.TrimPrefix(text, "name FutilityMargin value "), "FutilityMargin", &options.PruningMargins.Futility)
+	} else if strings.HasPrefix(text, "name RazoringMargin value ") {
+		return setPruningMargin(strings.TrimPrefix(text, "name RazoringMargin value "), "RazoringMargin", &options.PruningMargins.Razoring)
+	} else if strings.HasPrefix(text, "name InternalIterative value ") {
+		text = strings.TrimPrefix(text, "name InternalIterative value ")
+		mode := slices.Index(internalIterativeNames, text)
+		if mode < 0 {
+			return fmt.Errorf("invalid InternalIterative option, wanted: [Off/IIR], got: %s", text)
+		}
+		options.InternalIterative = engine.InternalIterativeMode(mode)
+		searchEngine.SetInternalIterativeMode(options.InternalIterative)
 	} else if text == "name Clear Hash" {
-		engine.TTReset(gameBoard, uint64(options.Hash))
+		searchEngine.TTReset(gameBoard, uint64(options.Hash))
 	} else {
 		return fmt.Errorf("invalid option: %s", text)
 	}
 	return nil
 }
 
+// Parses one of the pruning margin options into margin and hands all margins to the engine
+func setPruningMargin(text, optionName string, margin *int) error {
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 0 || value > 1000 {
+		return fmt.Errorf("invalid %s option, wanted: [0-1000], got: %s", optionName, text)
+	}
+	*margin = value
+	searchEngine.SetPruningMargins(options.PruningMargins)
+	return nil
+}
+
 // commandUCI is the response to the UCI command
 func commandUCI() error {
 	fmt.Println("id name", name)
@@ -147,7 +280,8 @@ func commandIsReady() error {
 
 func commandUCINewGame() {
 	gameBoard = nil
-	engine.TTReset(gameBoard, uint64(options.Hash))
+	searchEngine.TTReset(gameBoard, uint64(options.Hash))
+	searchEngine.ClearHistory()
 }
 
 // commandPosition is the response to the position command
@@ -250,9 +384,13 @@ func commandGo(text string) (engine.Move, error) {
 	// Reset the search cancel channel to open
 	searchCancelChannel = make(chan struct{})
 
-	var timeInMilliseconds int64 = 0
-	var remainingMoves int64 = 0
-	var fixedMoveTime bool = false
+	clock := timemanager.Limits{MoveOverhead: options.MoveOverhead, GamePhase: engine.GetGamePhase(gameBoard)}
+	var depth int64 = 0
+	var nodes uint64 = 0
+	var mate int64 = 0
+	var ponder bool = false
+	var searchMoves []engine.Move
+	var restrictedRoot bool = false
 
 	// Get the time to search for
 	text = strings.TrimPrefix(text, "go ")
@@ -274,22 +412,55 @@ func commandGo(text string) (engine.Move, error) {
 		switch textArr[i] {
 		case "wtime":
 			if gameBoard.GetTopState().TurnColor == engine.WHITE {
-				timeInMilliseconds, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+				clock.Time, _ = strconv.ParseInt(textArr[i+1], 10, 64)
 			}
 		case "btime":
 			if gameBoard.GetTopState().TurnColor == engine.BLACK {
-				timeInMilliseconds, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+				clock.Time, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+			}
+		case "winc":
+			if gameBoard.GetTopState().TurnColor == engine.WHITE {
+				clock.Increment, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+			}
+		case "binc":
+			if gameBoard.GetTopState().TurnColor == engine.BLACK {
+				clock.Increment, _ = strconv.ParseInt(textArr[i+1], 10, 64)
 			}
 		case "movetime":
-			timeInMilliseconds, _ = strconv.ParseInt(textArr[i+1], 10, 64)
-			fixedMoveTime = true
+			clock.MoveTime, _ = strconv.ParseInt(textArr[i+1], 10, 64)
 		case "movestogo":
-			remainingMoves, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+			clock.MovesToGo, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+		case "depth":
+			depth, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+		case "nodes":
+			nodes, _ = strconv.ParseUint(textArr[i+1], 10, 64)
+		case "mate":
+			mate, _ = strconv.ParseInt(textArr[i+1], 10, 64)
+		case "ponder":
+			ponder = true
+		case "searchmoves":
+			// Every following token up to the next go parameter is a move, the illegal ones are reported and left out
+			restrictedRoot = true
+			for ; i+1 < len(textArr) && !goParameters[textArr[i+1]]; i++ {
+				move, ok := gameBoard.TryMoveUCI(textArr[i+1])
+				if !ok {
+					fmt.Printf("info string invalid searchmoves move %s\n", textArr[i+1])
+					continue
+				}
+				searchMoves = append(searchMoves, move)
+			}
 		}
 	}
 
-	if options.OwnBook {
-		if bookMove := gameBoard.GetOpeningBookMove(); bookMove != engine.NULL_MOVE {
+	// Searching every move instead would ignore the restriction, but the GUI still waits for a bestmove
+	if restrictedRoot && len(searchMoves) == 0 {
+		stopSearch(searchCancelChannel)
+		fmt.Println("bestmove 0000")
+		return engine.NULL_MOVE, nil
+	}
+
+	if options.OwnBook && !ponder && !restrictedRoot {
+		if bookMove := gameBoard.GetOpeningBookMove(options.Deterministic); bookMove != engine.NULL_MOVE {
 			if uciDebug {
 				fmt.Println("Using opening book...")
 			}
@@ -298,27 +469,104 @@ func commandGo(text string) (engine.Move, error) {
 		}
 	}
 
-	if timeInMilliseconds != 0 {
-		if !fixedMoveTime && remainingMoves == 0 {
-			remainingMoves = int64(30 + (30*engine.GetGamePhase(gameBoard))/24)
+	// A ponder search thinks on the opponent's time until the GUI sends ponderhit or stop
+	cancelChannel := searchCancelChannel
+	ponderhitChannel := make(chan struct{})
+	if ponder {
+		searchCancelMutex.Lock()
+		searchPonderhitChannel = ponderhitChannel
+		searchCancelMutex.Unlock()
+	} else {
+		close(ponderhitChannel)
+	}
+
+	// The deterministic mode never looks at the clock, only depth, nodes and stop end its search
+	var timeManager *timemanager.TimeManager
+	if (clock.Time != 0 || clock.MoveTime != 0) && !options.Deterministic {
+		timeManager = timemanager.NewTimeManager(clock)
+		if uciDebug {
+			fmt.Printf("info string soft limit %dms hard limit %dms\n", timeManager.SoftLimit().Milliseconds(), timeManager.HardLimit().Milliseconds())
+		}
+		// A ponder search is only timed from ponderhit on, any other search from now on
+		if ponder {
+			go func() {
+				select {
+				case <-ponderhitChannel:
+				case <-cancelChannel:
+					return
+				}
+				timeManager.Start()
+			}()
 		} else {
-			remainingMoves++ // From 0 -> 1 for movetime, and movestogo -> movestogo+1 to avoid running out of time
+			timeManager.Start()
 		}
-		timeInMilliseconds = int64(float64(timeInMilliseconds) / float64(remainingMoves))
-		// Start a timer to close channel and end search at the end
-		go func() {
-			time.Sleep(time.Duration(timeInMilliseconds-1) * time.Millisecond)
-			close(searchCancelChannel)
-		}()
 	}
 
-	move := gameBoard.StartSearchNoDepth(time.Now(), searchCancelChannel)
-
-	fmt.Printf("bestmove %s\n", engine.MoveToString(move))
+	searchResult := searchEngine.Search(gameBoard, engine.SearchLimits{
+		StartTime:     time.Now(),
+		Depth:         int8(min(depth, engine.MAX_SEARCH_DEPTH)),
+		CancelChannel: cancelChannel,
+		SearchMoves:   searchMoves,
+		Nodes:         nodes,
+		Mate:          int8(min(mate, engine.MAX_SEARCH_DEPTH)),
+		TimeManager:   timeManager,
+		Deterministic: options.Deterministic,
+		Observer:      infoPrinter{},
+	})
+	move := searchResult.BestMove
+	// Even a ponder search that has run out of depth may only send its bestmove after ponderhit or stop
+	select {
+	case <-ponderhitChannel:
+	case <-cancelChannel:
+	}
+	// A search ended by its depth is done as well, so isready is answered without waiting on a stop
+	stopSearch(cancelChannel)
+	if ponder {
+		searchCancelMutex.Lock()
+		searchPonderhitChannel = nil
+		searchCancelMutex.Unlock()
+	}
+
+	if searchResult.Err != nil {
+		fmt.Printf("info string %v\n", searchResult.Err)
+		fmt.Println("bestmove 0000")
+		return engine.NULL_MOVE, nil
+	}
+	if mate > 0 && searchResult.MateIn() <= 0 {
+		fmt.Printf("info string no mate in %d found\n", mate)
+	}
+	if searchResult.PonderMove != engine.NULL_MOVE {
+		fmt.Printf("bestmove %s ponder %s\n", engine.MoveToString(move), engine.MoveToString(searchResult.PonderMove))
+	} else {
+		fmt.Printf("bestmove %s\n", engine.MoveToString(move))
+	}
 
 	return move, nil
 }
 
+// The opponent played the expected move, so the ponder search carries on as a normal timed search
+func commandPonderHit() error {
+	searchCancelMutex.Lock()
+	defer searchCancelMutex.Unlock()
+	if searchPonderhitChannel == nil {
+		return fmt.Errorf("ponderhit without a ponder search")
+	}
+	close(searchPonderhitChannel)
+	searchPonderhitChannel = nil
+	return nil
+}
+
+// Closes a search cancel channel, unless it has already been closed by stop or by the end of the search
+func stopSearch(cancelChannel chan struct{}) {
+	searchCancelMutex.Lock()
+	defer searchCancelMutex.Unlock()
+	select {
+	case <-cancelChannel:
+	default:
+		close(cancelChannel)
+	}
+}
+
 // Sets the debug mode to true or false
 func commandDebug(text string) error {
 	text = strings.TrimPrefix(text, "debug ")
@@ -468,6 +716,16 @@ func commandEval(text string) error {
 		sign = ""
 	}
 	fmt.Printf("Evaluation: %s%0.2f\n", sign, float32(eval)/100)
+
+	// Static exchange evaluation of every capture, in centipawns for the side to move
+	captureList := gameBoard.GenerateMoves(engine.CAPTURE, make([]engine.Move, 0, engine.MAX_MOVE_COUNT))
+	if len(captureList) > 0 {
+		fmt.Print("SEE:")
+		for _, move := range captureList {
+			fmt.Printf(" %s(%d)", engine.MoveToString(move), gameBoard.SEE(move))
+		}
+		fmt.Println()
+	}
 	return nil
 }
 
@@ -484,9 +742,9 @@ func commandBratkoKopec(text string) error {
 	if err != nil {
 		return err
 	}
-	totalCorrect, totalRun := testpositions.Run(time, options.Hash)
+	totalCorrect, totalRun := testpositions.Run(searchEngine, time, options.Hash)
 	fmt.Printf("%d/%d PASSED\n", totalCorrect, totalRun)
-	engine.TTReset(gameBoard, uint64(options.Hash))
+	searchEngine.TTReset(gameBoard, uint64(options.Hash))
 	return nil
 }
 
@@ -501,10 +759,10 @@ func commandDepthTest(text string) error {
 	fmt.Println()
 	startTime := time.Now()
 	searchCancelChannel = make(chan struct{})
-	move, score, timeTaken := gameBoard.StartSearchDepth(startTime, int8(depth), searchCancelChannel)
+	result := searchEngine.Search(gameBoard, engine.SearchLimits{StartTime: startTime, Depth: int8(depth), CancelChannel: searchCancelChannel, Observer: infoPrinter{}})
 	close(searchCancelChannel)
-	fmt.Printf("bestmove %s, nodes: %d, time: %dms\n", engine.MoveToString(move), score, timeTaken)
-	engine.TTReset(gameBoard, uint64(options.Hash))
+	fmt.Printf("bestmove %s, nodes: %d, time: %dms\n", engine.MoveToString(result.BestMove), result.Nodes, result.Time.Milliseconds())
+	searchEngine.TTReset(gameBoard, uint64(options.Hash))
 	return nil
 }
 
@@ -513,9 +771,9 @@ func commandBenchmark(text string) error {
 	if err != nil {
 		return err
 	}
-	avgNodes, avgTime := testpositions.Bench(int8(depth), options.Hash)
-	fmt.Printf("Bench Stats:\n\tAverage Nodes: %0.2f\n\tAverage Time: %0.2fms\n", avgNodes, avgTime)
-	engine.TTReset(gameBoard, uint64(options.Hash))
+	avgNodes, avgTime, nps := testpositions.Bench(searchEngine, int8(depth), options.Hash)
+	fmt.Printf("Bench Stats:\n\tAverage Nodes: %0.2f\n\tAverage Time: %0.2fms\n\tNodes/second: %0.0f\n", avgNodes, avgTime, nps)
+	searchEngine.TTReset(gameBoard, uint64(options.Hash))
 	return nil
 }
 
@@ -524,17 +782,27 @@ func commandHelp() error {
 	fmt.Println("\tisready - Check if the engine is ready")
 	fmt.Println("\tposition [startpos/fen <fen_string>] [moves <move_list>] - Set up the board position")
 	fmt.Println("\tgo - Start searching for the best move")
+	fmt.Println("\tponderhit - The opponent played the ponder move, switch the go ponder search to the normal time limit")
 	fmt.Println("\tucinewgame - Clear the board and reset the game")
 	fmt.Println("\tdebug [on/off] - Enable or disable debug mode")
 	fmt.Println("\tsetoption")
 	fmt.Println("\t\tname Hash <hash_size> - Set the hash table size in MB (default 16, min 1, max 1024)")
+	fmt.Println("\t\tname Threads <thread_count> - Set the number of search threads (default 1, min 1, max 128)")
+	fmt.Println("\t\tname MultiPV <line_count> - Set the number of best lines to search and report (default 1, min 1, max 256)")
+	fmt.Println("\t\tname Deterministic [true/false] - Sets if searches ignore the clock so they always give the same result")
+	fmt.Println("\t\tname Ponder [true/false] - Sets if the GUI will let the engine think on the opponent's time with go ponder")
+	fmt.Println("\t\tname Move Overhead <ms> - Set the time kept back per move for GUI and network lag (default 30, min 0, max 5000)")
+	fmt.Println("\t\tname ReverseFutilityMargin <cp> - Set the reverse futility pruning margin per ply (default 90, min 0, max 1000)")
+	fmt.Println("\t\tname FutilityMargin <cp> - Set the futility pruning margin per ply (default 150, min 0, max 1000)")
+	fmt.Println("\t\tname RazoringMargin <cp> - Set the razoring margin per ply (default 250, min 0, max 1000)")
+	fmt.Println("\t\tname InternalIterative [Off/IIR] - Set how nodes without a TT move are searched, as they are or reduced (default IIR)")
 	fmt.Println("\t\tname Clear Hash - Clears the Transposition Hash Table")
 	fmt.Println("\t\tname OwnBook [on/off] - Sets if engine can use saved book moves")
 	fmt.Println("\tpossiblemoves - Display all possible moves from the current position (debug mode only)")
 	fmt.Println("\tmove <move_uci> - Make a custom move, followed by the engine's move, on the current board (debug mode only)")
 	fmt.Println("\taimove - Tell engine to make best discovered move on the current board (debug mode only)")
 	fmt.Println("\tundomove - Undo the last move on the current board (debug mode only)")
-	fmt.Println("\teval - Evaluate the current position (debug mode only)")
+	fmt.Println("\teval - Evaluate the current position and the static exchange of every capture (debug mode only)")
 	fmt.Println("\thelp - Display this help message")
 	fmt.Println("\tquit - Exit the program")
 	return nil
@@ -542,6 +810,15 @@ func commandHelp() error {
 
 func optionList() {
 	fmt.Println("option name Hash type spin default 16 min 1 max 1024")
+	fmt.Println("option name Threads type spin default 1 min 1 max 128")
+	fmt.Println("option name MultiPV type spin default 1 min 1 max 256")
 	fmt.Println("option name Clear Hash type button")
 	fmt.Println("option name OwnBook type check default false")
+	fmt.Println("option name Deterministic type check default false")
+	fmt.Println("option name Ponder type check default false")
+	fmt.Println("option name Move Overhead type spin default 30 min 0 max 5000")
+	fmt.Printf("option name ReverseFutilityMargin type spin default %d min 0 max 1000\n", engine.DefaultPruningMargins.ReverseFutility)
+	fmt.Printf("option name FutilityMargin type spin default %d min 0 max 1000\n", engine.DefaultPruningMargins.Futility)
+	fmt.Printf("option name RazoringMargin type spin default %d min 0 max 1000\n", engine.DefaultPruningMargins.Razoring)
+	fmt.Println("option name InternalIterative type combo default IIR var Off var IIR")
 }
@@ -0,0 +1,164 @@
+package chessengine
+
+import (
+	"bufio"
+	engine "chessengine/src/engine"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Runs the UCI commands against the global engine, sending every line it prints to the returned channel
+func startUCITest(t *testing.T) (func(command string), chan string) {
+	engine.InitMagicBitBoardTable("../../magic_rook", "../../magic_bishop")
+	engine.InitZobristTable()
+	engine.InitPeSTO()
+	gameBoard = engine.InitStartBoard()
+	searchCancelChannel = make(chan struct{})
+	close(searchCancelChannel)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	lines := make(chan string, 1024)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		writer.Close()
+	})
+
+	send := func(command string) {
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(command + "\n"))); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+	}
+	return send, lines
+}
+
+// Waits for the bestmove line, failing if it does not come within timeout
+func waitBestMove(t *testing.T, lines chan string, timeout time.Duration) string {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line := <-lines:
+			if strings.HasPrefix(line, "bestmove") {
+				return line
+			}
+		case <-deadline:
+			t.Fatalf("No bestmove within %v", timeout)
+		}
+	}
+}
+
+// Fails if a bestmove line is printed within duration
+func expectNoBestMove(t *testing.T, lines chan string, duration time.Duration) {
+	deadline := time.After(duration)
+	for {
+		select {
+		case line := <-lines:
+			if strings.HasPrefix(line, "bestmove") {
+				t.Fatalf("Ponder search sent %q before ponderhit or stop", line)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Checks that the bestmove line plays a legal move and ponders on a legal reply to it
+func checkBestMove(t *testing.T, line string) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[2] != "ponder" {
+		t.Fatalf("Expected a bestmove with a ponder move, got %q", line)
+	}
+	board := gameBoard.DeepCopy()
+	move, ok := board.TryMoveUCI(fields[1])
+	if !ok {
+		t.Fatalf("Illegal best move in %q", line)
+	}
+	board.MakeMove(move)
+	if _, ok := board.TryMoveUCI(fields[3]); !ok {
+		t.Fatalf("Illegal ponder move in %q", line)
+	}
+}
+
+func Test_UCIPonderHit(t *testing.T) {
+	send, lines := startUCITest(t)
+
+	send("position startpos moves e2e4 e7e5")
+	send("go ponder wtime 2000 btime 2000")
+	// Pondering ignores the clock, the search would be long over otherwise
+	expectNoBestMove(t, lines, 1500*time.Millisecond)
+	startTime := time.Now()
+	send("ponderhit")
+	checkBestMove(t, waitBestMove(t, lines, 2*time.Second))
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Fatalf("Search took %v after ponderhit with 2s on the clock", elapsed)
+	}
+}
+
+func Test_UCIPonderStop(t *testing.T) {
+	send, lines := startUCITest(t)
+
+	send("position startpos moves d2d4")
+	send("go ponder depth 3")
+	// Even a search that has reached its depth keeps its bestmove until it is told the ponder move was not played
+	expectNoBestMove(t, lines, 500*time.Millisecond)
+	send("stop")
+	checkBestMove(t, waitBestMove(t, lines, time.Second))
+
+	send("position startpos moves d2d4 d7d5")
+	send("go depth 3")
+	checkBestMove(t, waitBestMove(t, lines, 5*time.Second))
+}
+
+func Test_UCIMoveTime(t *testing.T) {
+	send, lines := startUCITest(t)
+
+	send("position startpos")
+	startTime := time.Now()
+	send("go movetime 200")
+	checkBestMove(t, waitBestMove(t, lines, 2*time.Second))
+	if elapsed := time.Since(startTime); elapsed > 400*time.Millisecond {
+		t.Fatalf("go movetime 200 took %v", elapsed)
+	}
+}
+
+func Test_UCIInvalidSearchMoves(t *testing.T) {
+	send, lines := startUCITest(t)
+
+	// The illegal entry is reported and left out, the search goes on with the legal one
+	send("position startpos")
+	send("go depth 3 searchmoves e2e5 a2a3")
+	var info []string
+	deadline := time.After(5 * time.Second)
+	for len(info) == 0 || !strings.HasPrefix(info[len(info)-1], "bestmove") {
+		select {
+		case line := <-lines:
+			info = append(info, line)
+		case <-deadline:
+			t.Fatalf("No bestmove after %v", info)
+		}
+	}
+	if info[0] != "info string invalid searchmoves move e2e5" {
+		t.Fatalf("Invalid searchmoves move reported as %q", info[0])
+	}
+	if fields := strings.Fields(info[len(info)-1]); fields[1] != "a2a3" {
+		t.Fatalf("searchmoves e2e5 a2a3 answered %q", info[len(info)-1])
+	}
+
+	// Nothing legal left to search, the restriction still holds and the GUI still gets its bestmove
+	send("go depth 3 searchmoves e2e5")
+	if line := waitBestMove(t, lines, time.Second); line != "bestmove 0000" {
+		t.Fatalf("searchmoves without a legal move answered %q", line)
+	}
+}